@@ -0,0 +1,127 @@
+// Команда accrualmock запускает имитацию системы расчета начислений в памяти.
+// Реализует GET /api/orders/{number}, POST /api/orders и POST /api/goods из SPECIFICATION.md
+// и не требует базы данных.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gophermart/internal/accrualmock"
+	"gophermart/internal/domain"
+)
+
+const (
+	defaultRunAddress      = ":8081"
+	defaultProcessingDelay = 2 * time.Second
+	defaultSlowDelay       = 5 * time.Second
+	readHeaderTimeout      = 5 * time.Second
+	shutdownTimeout        = 5 * time.Second
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run запускает сервер и возвращает код выхода.
+func run() int {
+	var (
+		address   string
+		rulesPath string
+		cfg       accrualmock.Config
+	)
+
+	flag.StringVar(&address, "a", envOrDefault("RUN_ADDRESS", defaultRunAddress), "Адрес и порт для запуска сервера")
+	flag.StringVar(&rulesPath, "rules", "", "JSON-файл с начальными правилами вознаграждения")
+	flag.DurationVar(&cfg.ProcessingDelay, "delay", defaultProcessingDelay,
+		"Время от регистрации заказа до окончательного статуса")
+	flag.IntVar(&cfg.RateLimit, "rate-limit", 0, "Лимит запросов информации о заказе в минуту (0 - без ограничения)")
+	flag.Float64Var(&cfg.ErrorRate, "error-rate", 0, "Доля запросов, на которые сервер отвечает 500")
+	flag.Float64Var(&cfg.SlowRate, "slow-rate", 0, "Доля запросов, ответ на которые задерживается")
+	flag.DurationVar(&cfg.SlowDelay, "slow-delay", defaultSlowDelay, "Задержка медленных ответов")
+	flag.Func("auto-reward",
+		"Начисление для незарегистрированных заказов с верным номером (по умолчанию отвечать 204)",
+		func(value string) error {
+			reward, err := domain.ParseMoney(value)
+			cfg.AutoReward = reward
+			return err
+		})
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	if rulesPath != "" {
+		rules, err := loadRules(rulesPath)
+		if err != nil {
+			logger.Error("failed to load rules", "error", err)
+			return 1
+		}
+		cfg.Rules = rules
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           accrualmock.NewServer(cfg, logger).Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("имитация системы начислений запущена", "address", address, "rules", len(cfg.Rules))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server error", "error", err)
+			return 1
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shutdown server", "error", err)
+		return 1
+	}
+
+	return 0
+}
+
+// loadRules читает правила вознаграждения из JSON-файла с массивом правил.
+func loadRules(path string) ([]accrualmock.Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var rules []accrualmock.Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	return rules, nil
+}
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию.
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
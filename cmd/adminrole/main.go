@@ -0,0 +1,96 @@
+// Команда adminrole назначает или снимает роль admin пользователю по его идентификатору.
+// Роль проверяется по базе при каждом запросе, поэтому изменение действует сразу, без перезапуска сервиса.
+//
+//	go run ./cmd/adminrole -user-id 42          # назначить роль admin
+//	go run ./cmd/adminrole -user-id 42 -revoke  # снять роль admin
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"gophermart/internal/app"
+	"gophermart/internal/domain"
+	"gophermart/internal/repository"
+)
+
+const (
+	connectTimeout = 10 * time.Second
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run меняет роль пользователя и возвращает код выхода.
+func run() int {
+	// Загрузка .env файла, если он существует
+	_ = godotenv.Load()
+
+	var (
+		databaseURI string
+		userID      int
+		revoke      bool
+	)
+	flag.StringVar(&databaseURI, "d", os.Getenv("DATABASE_URI"), "URI базы данных")
+	flag.IntVar(&userID, "user-id", 0, "Идентификатор пользователя")
+	flag.BoolVar(&revoke, "revoke", false, "Снять роль admin вместо назначения")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	if userID <= 0 {
+		logger.Error("user id is required", "user_id", userID)
+		flag.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	db, err := app.NewDB(ctx, databaseURI)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	role, action := domain.RoleAdmin, domain.AuditRoleGranted
+	if revoke {
+		role, action = domain.RoleUser, domain.AuditRoleRevoked
+	}
+
+	err = repository.NewUserRepo(db).SetRole(ctx, userID, role)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Error("user not found", "user_id", userID)
+		return 1
+	}
+	if err != nil {
+		logger.Error("failed to set user role", "user_id", userID, "error", err)
+		return 1
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{"role": role})
+	if err = repository.NewAuditRepo(db, logger).Append(&domain.AuditEvent{
+		ActorType:  domain.AuditActorSystem,
+		Action:     action,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   strconv.Itoa(userID),
+		Payload:    payload,
+	}); err != nil {
+		logger.Error("failed to record audit event", "user_id", userID, "error", err)
+		return 1
+	}
+
+	logger.Info("user role updated", "user_id", userID, "role", role)
+	return 0
+}
@@ -3,21 +3,68 @@ package main
 import (
 	"flag"
 	"os"
+	"strconv"
 	"time"
 )
 
 const (
-	defaultJWTExpirationHours = 24
+	defaultJWTExpiration       = 15 * time.Minute
+	defaultRefreshTTLHours     = 30 * 24
+	defaultLoginMaxFailures    = 5
+	defaultLoginIPMaxFailures  = 20
+	defaultLoginLockout        = 1 * time.Minute
+	defaultLoginMaxLockout     = 1 * time.Hour
+	defaultPasswordMinLength   = 6
+	defaultIdempotencyTTLHours = 24
+	defaultIdempotencyLease    = 1 * time.Minute
+	defaultAccrualMaxAttempts  = 50
+	defaultBreakerFailures     = 5
+	defaultBreakerSlowCall     = 5 * time.Second
+	defaultBreakerOpenTimeout  = 30 * time.Second
+	defaultAccrualMaxAgeHours  = 72
+	defaultAccrualPollInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultShutdownHTTP        = 10 * time.Second
+	defaultShutdownWorker      = 10 * time.Second
+	defaultShutdownDB          = 5 * time.Second
 )
 
 // Config содержит конфигурацию приложения.
 type Config struct {
-	RunAddress           string        // Адрес и порт для запуска сервера
-	DatabaseURI          string        // URI базы данных
-	AccrualSystemAddress string        // Адрес системы расчета начислений
-	MigrationsDirectory  string        // Директория с миграциями
-	JWTSecret            string        // Секретный ключ для подписи JWT токенов
-	JWTExpirationPeriod  time.Duration // Период действия JWT токена
+	RunAddress                string        // Адрес и порт для запуска сервера
+	DatabaseURI               string        // URI базы данных
+	AccrualSystemAddress      string        // Адрес системы расчета начислений
+	AccrualRateLimit          float64       // Темп запросов к системе начислений в секунду
+	MigrationsDirectory       string        // Директория с миграциями
+	JWTSecret                 string        // Секретный ключ для подписи JWT токенов HS256
+	JWTSecretKID              string        // Идентификатор (kid) ключа JWTSecret
+	JWTKeysDir                string        // Каталог с PEM-ключами подписи JWT
+	JWTExpirationPeriod       time.Duration // Период действия JWT (access) токена
+	RefreshTokenTTL           time.Duration // Период действия refresh-токена
+	LoginMaxFailures          int           // Неудачных входов в учетную запись до блокировки
+	LoginIPMaxFailures        int           // Неудачных входов с одного IP-адреса до блокировки
+	LoginLockout              time.Duration // Длительность первой блокировки входа
+	LoginMaxLockout           time.Duration // Максимальная длительность блокировки входа
+	TrustProxyHeaders         bool          // Определять IP-адрес клиента по X-Forwarded-For
+	PasswordMinLength         int           // Минимальная длина пароля
+	PasswordMinClasses        int           // Минимальное число классов символов в пароле
+	PasswordBlocklistFile     string        // Файл с запрещенными паролями
+	PasswordHashAlgorithm     string        // Алгоритм хеширования новых паролей
+	IdempotencyTTL            time.Duration // Время хранения ответов по ключу идемпотентности
+	IdempotencyLease          time.Duration // Аренда ключа идемпотентности выполняющимся запросом
+	AccrualBreakerFailures    int           // Число ошибок подряд, после которого цепь размыкается
+	AccrualBreakerSlowCall    time.Duration // Запросы дольше этого времени считаются ошибкой
+	AccrualBreakerOpenTimeout time.Duration // Время в разомкнутом состоянии до пробного запроса
+	AccrualMaxAttempts        int           // Максимальное число опросов системы начислений по заказу
+	AccrualMaxAge             time.Duration // Максимальное время ожидания расчета по заказу
+	AccrualPollInterval       time.Duration // Интервал страховочного опроса очереди заказов
+	TracingExporter           string        // Экспортер трассировки: none, otlp, stdout
+	OTLPEndpoint              string        // URL коллектора OTLP/HTTP
+	HealthCheckTimeout        time.Duration // Таймаут каждой проверки готовности
+	ShutdownReadinessDelay    time.Duration // Пауза после снятия готовности до закрытия слушателя
+	ShutdownHTTPTimeout       time.Duration // Время на завершение выполняющихся HTTP-запросов
+	ShutdownWorkerTimeout     time.Duration // Время на завершение текущих заказов воркерами
+	ShutdownDBTimeout         time.Duration // Время на закрытие пула соединений с базой данных
 }
 
 // parseFlags парсит флаги командной строки и переменные окружения.
@@ -33,18 +80,186 @@ func parseFlags() Config {
 		getEnvOrDefault("ACCRUAL_SYSTEM_ADDRESS"),
 		"Адрес системы расчета начислений",
 	)
+	flag.Float64Var(
+		&cfg.AccrualRateLimit,
+		"accrual-rate-limit",
+		getFloatEnv("ACCRUAL_RATE_LIMIT", 0),
+		"Темп запросов к системе начислений в секунду (0 - без ограничения до первого ответа 429)",
+	)
+	flag.IntVar(
+		&cfg.AccrualBreakerFailures,
+		"accrual-breaker-failures",
+		getIntEnv("ACCRUAL_BREAKER_FAILURES", defaultBreakerFailures),
+		"Число ошибок системы начислений подряд, после которого запросы приостанавливаются",
+	)
+	flag.DurationVar(
+		&cfg.AccrualBreakerSlowCall,
+		"accrual-breaker-slow-call",
+		getDurationEnv("ACCRUAL_BREAKER_SLOW_CALL", defaultBreakerSlowCall),
+		"Время ответа системы начислений, после которого запрос считается ошибкой (меньше нуля - не учитывать)",
+	)
+	flag.DurationVar(
+		&cfg.AccrualBreakerOpenTimeout,
+		"accrual-breaker-open-timeout",
+		getDurationEnv("ACCRUAL_BREAKER_OPEN_TIMEOUT", defaultBreakerOpenTimeout),
+		"Пауза в запросах к системе начислений перед пробным запросом",
+	)
 	flag.StringVar(&cfg.MigrationsDirectory, "m", "migrations", "Директория с миграциями")
 	flag.StringVar(
 		&cfg.JWTSecret,
 		"jwt-secret",
 		getEnvOrDefault("JWT_SECRET"),
-		"Секретный ключ для подписи JWT токенов",
+		"Секретный ключ для подписи JWT токенов HS256",
+	)
+	flag.StringVar(
+		&cfg.JWTSecretKID,
+		"jwt-secret-kid",
+		getEnvOrDefault("JWT_SECRET_KID"),
+		"Идентификатор (kid) ключа JWT_SECRET; пусто - выводится из секрета",
+	)
+	flag.StringVar(
+		&cfg.JWTKeysDir,
+		"jwt-keys-dir",
+		getEnvOrDefault("JWT_KEYS_DIR"),
+		"Каталог с PEM-ключами подписи JWT (RS256, EdDSA); новые токены подписываются последним по имени файла",
 	)
 	flag.DurationVar(
 		&cfg.JWTExpirationPeriod,
 		"jwt-exp",
-		getDurationEnv("JWT_EXPIRATION_PERIOD", defaultJWTExpirationHours*time.Hour),
-		"Период действия JWT токена",
+		getDurationEnv("JWT_EXPIRATION_PERIOD", defaultJWTExpiration),
+		"Период действия JWT (access) токена",
+	)
+	flag.DurationVar(
+		&cfg.RefreshTokenTTL,
+		"refresh-token-ttl",
+		getDurationEnv("REFRESH_TOKEN_TTL", defaultRefreshTTLHours*time.Hour),
+		"Период действия refresh-токена",
+	)
+	flag.IntVar(
+		&cfg.LoginMaxFailures,
+		"login-max-failures",
+		getIntEnv("LOGIN_MAX_FAILURES", defaultLoginMaxFailures),
+		"Число неудачных входов в учетную запись, после которого вход блокируется",
+	)
+	flag.IntVar(
+		&cfg.LoginIPMaxFailures,
+		"login-ip-max-failures",
+		getIntEnv("LOGIN_IP_MAX_FAILURES", defaultLoginIPMaxFailures),
+		"Число неудачных входов с одного IP-адреса, после которого вход с него блокируется",
+	)
+	flag.DurationVar(
+		&cfg.LoginLockout,
+		"login-lockout",
+		getDurationEnv("LOGIN_LOCKOUT", defaultLoginLockout),
+		"Длительность первой блокировки входа; каждая следующая вдвое дольше",
+	)
+	flag.DurationVar(
+		&cfg.LoginMaxLockout,
+		"login-max-lockout",
+		getDurationEnv("LOGIN_MAX_LOCKOUT", defaultLoginMaxLockout),
+		"Максимальная длительность блокировки входа",
+	)
+	flag.BoolVar(
+		&cfg.TrustProxyHeaders,
+		"trust-proxy-headers",
+		getBoolEnv("TRUST_PROXY_HEADERS", false),
+		"Определять IP-адрес клиента по заголовку X-Forwarded-For (только за доверенным прокси)",
+	)
+	flag.IntVar(
+		&cfg.PasswordMinLength,
+		"password-min-length",
+		getIntEnv("PASSWORD_MIN_LENGTH", defaultPasswordMinLength),
+		"Минимальная длина пароля",
+	)
+	flag.IntVar(
+		&cfg.PasswordMinClasses,
+		"password-min-classes",
+		getIntEnv("PASSWORD_MIN_CLASSES", 0),
+		"Сколько классов символов (строчные, заглавные, цифры, прочие) должно быть в пароле",
+	)
+	flag.StringVar(
+		&cfg.PasswordBlocklistFile,
+		"password-blocklist",
+		getEnvOrDefault("PASSWORD_BLOCKLIST_FILE"),
+		"Файл с запрещенными паролями, по одному в строке",
+	)
+	flag.StringVar(
+		&cfg.PasswordHashAlgorithm,
+		"password-hash",
+		getEnvOrDefault("PASSWORD_HASH_ALGORITHM"),
+		"Алгоритм хеширования новых паролей: bcrypt (по умолчанию) или argon2id; старые хеши пересчитываются при входе",
+	)
+	flag.DurationVar(
+		&cfg.IdempotencyTTL,
+		"idempotency-ttl",
+		getDurationEnv("IDEMPOTENCY_TTL", defaultIdempotencyTTLHours*time.Hour),
+		"Время хранения ответов по ключу идемпотентности",
+	)
+	flag.DurationVar(
+		&cfg.IdempotencyLease,
+		"idempotency-lease",
+		getDurationEnv("IDEMPOTENCY_LEASE", defaultIdempotencyLease),
+		"Время, после которого ключ незавершенного запроса (паника, падение процесса) можно занять повтором",
+	)
+	flag.IntVar(
+		&cfg.AccrualMaxAttempts,
+		"accrual-max-attempts",
+		getIntEnv("ACCRUAL_MAX_ATTEMPTS", defaultAccrualMaxAttempts),
+		"Максимальное число опросов системы начислений по заказу (0 - без ограничения)",
+	)
+	flag.DurationVar(
+		&cfg.AccrualMaxAge,
+		"accrual-max-age",
+		getDurationEnv("ACCRUAL_MAX_AGE", defaultAccrualMaxAgeHours*time.Hour),
+		"Максимальное время ожидания расчета по заказу (0 - без ограничения)",
+	)
+	flag.DurationVar(
+		&cfg.AccrualPollInterval,
+		"accrual-poll-interval",
+		getDurationEnv("ACCRUAL_POLL_INTERVAL", defaultAccrualPollInterval),
+		"Интервал страховочного опроса очереди заказов (новые заказы обрабатываются по уведомлению)",
+	)
+	flag.StringVar(
+		&cfg.TracingExporter,
+		"tracing-exporter",
+		getEnvOrDefault("TRACING_EXPORTER"),
+		"Экспортер трассировки OpenTelemetry: none (по умолчанию), otlp или stdout",
+	)
+	flag.StringVar(
+		&cfg.OTLPEndpoint,
+		"otlp-endpoint",
+		getEnvOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		"URL коллектора OTLP/HTTP, например http://localhost:4318",
+	)
+	flag.DurationVar(
+		&cfg.HealthCheckTimeout,
+		"health-check-timeout",
+		getDurationEnv("HEALTH_CHECK_TIMEOUT", defaultHealthCheckTimeout),
+		"Таймаут каждой проверки готовности /readyz",
+	)
+	flag.DurationVar(
+		&cfg.ShutdownReadinessDelay,
+		"shutdown-readiness-delay",
+		getDurationEnv("SHUTDOWN_READINESS_DELAY", 0),
+		"Пауза при остановке между снятием готовности и закрытием слушателя",
+	)
+	flag.DurationVar(
+		&cfg.ShutdownHTTPTimeout,
+		"shutdown-http-timeout",
+		getDurationEnv("SHUTDOWN_HTTP_TIMEOUT", defaultShutdownHTTP),
+		"Время на завершение выполняющихся HTTP-запросов при остановке",
+	)
+	flag.DurationVar(
+		&cfg.ShutdownWorkerTimeout,
+		"shutdown-worker-timeout",
+		getDurationEnv("SHUTDOWN_WORKER_TIMEOUT", defaultShutdownWorker),
+		"Время на завершение текущих заказов воркерами при остановке",
+	)
+	flag.DurationVar(
+		&cfg.ShutdownDBTimeout,
+		"shutdown-db-timeout",
+		getDurationEnv("SHUTDOWN_DB_TIMEOUT", defaultShutdownDB),
+		"Время на закрытие пула соединений с базой данных при остановке",
 	)
 
 	return cfg
@@ -67,3 +282,33 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getIntEnv получает целочисленное значение из переменной окружения.
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getBoolEnv получает логическое значение из переменной окружения.
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getFloatEnv получает дробное значение из переменной окружения.
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/joho/godotenv"
@@ -43,7 +44,44 @@ func main() {
 		"DATABASE_URI", getVarSource("DATABASE_URI", cfg.DatabaseURI, envFileLoaded),
 		"ACCRUAL_SYSTEM_ADDRESS", getVarSource("ACCRUAL_SYSTEM_ADDRESS", cfg.AccrualSystemAddress, envFileLoaded),
 		"JWT_SECRET", maskSecret(getVarSource("JWT_SECRET", cfg.JWTSecret, envFileLoaded)),
+		"JWT_SECRET_KID", getVarSource("JWT_SECRET_KID", cfg.JWTSecretKID, envFileLoaded),
+		"JWT_KEYS_DIR", getVarSource("JWT_KEYS_DIR", cfg.JWTKeysDir, envFileLoaded),
 		"JWT_EXPIRATION_PERIOD", getVarSource("JWT_EXPIRATION_PERIOD", cfg.JWTExpirationPeriod.String(), envFileLoaded),
+		"REFRESH_TOKEN_TTL", getVarSource("REFRESH_TOKEN_TTL", cfg.RefreshTokenTTL.String(), envFileLoaded),
+		"LOGIN_MAX_FAILURES", getVarSource("LOGIN_MAX_FAILURES", strconv.Itoa(cfg.LoginMaxFailures), envFileLoaded),
+		"LOGIN_IP_MAX_FAILURES", getVarSource(
+			"LOGIN_IP_MAX_FAILURES", strconv.Itoa(cfg.LoginIPMaxFailures), envFileLoaded),
+		"LOGIN_LOCKOUT", getVarSource("LOGIN_LOCKOUT", cfg.LoginLockout.String(), envFileLoaded),
+		"LOGIN_MAX_LOCKOUT", getVarSource("LOGIN_MAX_LOCKOUT", cfg.LoginMaxLockout.String(), envFileLoaded),
+		"PASSWORD_MIN_LENGTH", getVarSource("PASSWORD_MIN_LENGTH", strconv.Itoa(cfg.PasswordMinLength), envFileLoaded),
+		"PASSWORD_MIN_CLASSES", getVarSource("PASSWORD_MIN_CLASSES", strconv.Itoa(cfg.PasswordMinClasses), envFileLoaded),
+		"PASSWORD_BLOCKLIST_FILE", getVarSource("PASSWORD_BLOCKLIST_FILE", cfg.PasswordBlocklistFile, envFileLoaded),
+		"PASSWORD_HASH_ALGORITHM", getVarSource("PASSWORD_HASH_ALGORITHM", cfg.PasswordHashAlgorithm, envFileLoaded),
+		"TRUST_PROXY_HEADERS", getVarSource(
+			"TRUST_PROXY_HEADERS", strconv.FormatBool(cfg.TrustProxyHeaders), envFileLoaded),
+		"IDEMPOTENCY_TTL", getVarSource("IDEMPOTENCY_TTL", cfg.IdempotencyTTL.String(), envFileLoaded),
+		"IDEMPOTENCY_LEASE", getVarSource("IDEMPOTENCY_LEASE", cfg.IdempotencyLease.String(), envFileLoaded),
+		"ACCRUAL_RATE_LIMIT", getVarSource(
+			"ACCRUAL_RATE_LIMIT", strconv.FormatFloat(cfg.AccrualRateLimit, 'f', -1, 64), envFileLoaded),
+		"ACCRUAL_BREAKER_FAILURES", getVarSource(
+			"ACCRUAL_BREAKER_FAILURES", strconv.Itoa(cfg.AccrualBreakerFailures), envFileLoaded),
+		"ACCRUAL_BREAKER_SLOW_CALL", getVarSource(
+			"ACCRUAL_BREAKER_SLOW_CALL", cfg.AccrualBreakerSlowCall.String(), envFileLoaded),
+		"ACCRUAL_BREAKER_OPEN_TIMEOUT", getVarSource(
+			"ACCRUAL_BREAKER_OPEN_TIMEOUT", cfg.AccrualBreakerOpenTimeout.String(), envFileLoaded),
+		"ACCRUAL_MAX_ATTEMPTS", getVarSource("ACCRUAL_MAX_ATTEMPTS", strconv.Itoa(cfg.AccrualMaxAttempts), envFileLoaded),
+		"ACCRUAL_MAX_AGE", getVarSource("ACCRUAL_MAX_AGE", cfg.AccrualMaxAge.String(), envFileLoaded),
+		"ACCRUAL_POLL_INTERVAL", getVarSource(
+			"ACCRUAL_POLL_INTERVAL", cfg.AccrualPollInterval.String(), envFileLoaded),
+		"TRACING_EXPORTER", getVarSource("TRACING_EXPORTER", cfg.TracingExporter, envFileLoaded),
+		"OTEL_EXPORTER_OTLP_ENDPOINT", getVarSource("OTEL_EXPORTER_OTLP_ENDPOINT", cfg.OTLPEndpoint, envFileLoaded),
+		"HEALTH_CHECK_TIMEOUT", getVarSource("HEALTH_CHECK_TIMEOUT", cfg.HealthCheckTimeout.String(), envFileLoaded),
+		"SHUTDOWN_READINESS_DELAY", getVarSource(
+			"SHUTDOWN_READINESS_DELAY", cfg.ShutdownReadinessDelay.String(), envFileLoaded),
+		"SHUTDOWN_HTTP_TIMEOUT", getVarSource("SHUTDOWN_HTTP_TIMEOUT", cfg.ShutdownHTTPTimeout.String(), envFileLoaded),
+		"SHUTDOWN_WORKER_TIMEOUT", getVarSource(
+			"SHUTDOWN_WORKER_TIMEOUT", cfg.ShutdownWorkerTimeout.String(), envFileLoaded),
+		"SHUTDOWN_DB_TIMEOUT", getVarSource("SHUTDOWN_DB_TIMEOUT", cfg.ShutdownDBTimeout.String(), envFileLoaded),
 	)
 
 	// Создаем контекст с отменой
@@ -63,12 +101,40 @@ func main() {
 
 	// Запускаем приложение
 	application, err := app.New(ctx, app.Config{
-		DatabaseURI:          cfg.DatabaseURI,
-		MigrationsDir:        cfg.MigrationsDirectory,
-		RunAddress:           cfg.RunAddress,
-		AccrualSystemAddress: cfg.AccrualSystemAddress,
-		JWTSecret:            cfg.JWTSecret,
-		JWTExpirationPeriod:  cfg.JWTExpirationPeriod,
+		DatabaseURI:               cfg.DatabaseURI,
+		MigrationsDir:             cfg.MigrationsDirectory,
+		RunAddress:                cfg.RunAddress,
+		AccrualSystemAddress:      cfg.AccrualSystemAddress,
+		AccrualRateLimit:          cfg.AccrualRateLimit,
+		JWTSecret:                 cfg.JWTSecret,
+		JWTSecretKID:              cfg.JWTSecretKID,
+		JWTKeysDir:                cfg.JWTKeysDir,
+		JWTExpirationPeriod:       cfg.JWTExpirationPeriod,
+		RefreshTokenTTL:           cfg.RefreshTokenTTL,
+		LoginMaxFailures:          cfg.LoginMaxFailures,
+		LoginIPMaxFailures:        cfg.LoginIPMaxFailures,
+		LoginLockout:              cfg.LoginLockout,
+		LoginMaxLockout:           cfg.LoginMaxLockout,
+		TrustProxyHeaders:         cfg.TrustProxyHeaders,
+		PasswordMinLength:         cfg.PasswordMinLength,
+		PasswordMinClasses:        cfg.PasswordMinClasses,
+		PasswordBlocklistFile:     cfg.PasswordBlocklistFile,
+		PasswordHashAlgorithm:     cfg.PasswordHashAlgorithm,
+		IdempotencyTTL:            cfg.IdempotencyTTL,
+		IdempotencyLease:          cfg.IdempotencyLease,
+		AccrualBreakerFailures:    cfg.AccrualBreakerFailures,
+		AccrualBreakerSlowCall:    cfg.AccrualBreakerSlowCall,
+		AccrualBreakerOpenTimeout: cfg.AccrualBreakerOpenTimeout,
+		AccrualMaxAttempts:        cfg.AccrualMaxAttempts,
+		AccrualMaxAge:             cfg.AccrualMaxAge,
+		AccrualPollInterval:       cfg.AccrualPollInterval,
+		TracingExporter:           cfg.TracingExporter,
+		OTLPEndpoint:              cfg.OTLPEndpoint,
+		HealthCheckTimeout:        cfg.HealthCheckTimeout,
+		ShutdownReadinessDelay:    cfg.ShutdownReadinessDelay,
+		ShutdownHTTPTimeout:       cfg.ShutdownHTTPTimeout,
+		ShutdownWorkerTimeout:     cfg.ShutdownWorkerTimeout,
+		ShutdownDBTimeout:         cfg.ShutdownDBTimeout,
 	})
 	if err != nil {
 		slog.Error("failed to initialize application", "error", err)
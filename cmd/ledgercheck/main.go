@@ -0,0 +1,60 @@
+// Команда ledgercheck сверяет журнал проводок с таблицами orders, withdrawals, balance_adjustments и balances.
+// Завершается с кодом 1, если найдены расхождения.
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"gophermart/internal/app"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+)
+
+const (
+	connectTimeout = 10 * time.Second
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run выполняет проверку и возвращает код выхода.
+func run() int {
+	// Загрузка .env файла, если он существует
+	_ = godotenv.Load()
+
+	var databaseURI string
+	flag.StringVar(&databaseURI, "d", os.Getenv("DATABASE_URI"), "URI базы данных")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	db, err := app.NewDB(ctx, databaseURI)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	ledgerService := service.NewLedgerService(repository.NewLedgerRepo(db, logger), logger)
+	discrepancies, err := ledgerService.CheckConsistency()
+	if err != nil {
+		logger.Error("failed to check ledger consistency", "error", err)
+		return 1
+	}
+
+	if len(discrepancies) > 0 {
+		return 1
+	}
+
+	return 0
+}
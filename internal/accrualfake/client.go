@@ -0,0 +1,140 @@
+// Package accrualfake содержит детерминированную реализацию domain.AccrualClient в памяти.
+// Ответы для каждого заказа задаются сценарием, что позволяет воспроизводить смену статусов,
+// ответы 204, 429 с Retry-After и неразбираемые ответы без HTTP-сервера.
+package accrualfake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// stepKind тип шага сценария.
+type stepKind int
+
+const (
+	stepStatus stepKind = iota
+	stepNotFound
+	stepRateLimit
+	stepMalformed
+	stepError
+)
+
+// Step один ответ сценария заказа.
+type Step struct {
+	kind       stepKind
+	status     domain.OrderStatus
+	accrual    *domain.Money
+	retryAfter time.Duration
+	err        error
+}
+
+// Status возвращает шаг с ответом о статусе заказа без начисления.
+func Status(status domain.OrderStatus) Step {
+	return Step{kind: stepStatus, status: status}
+}
+
+// Processed возвращает шаг с окончательным статусом PROCESSED и начислением.
+func Processed(accrual domain.Money) Step {
+	return Step{kind: stepStatus, status: domain.OrderStatusProcessed, accrual: &accrual}
+}
+
+// NotFound возвращает шаг с ответом 204: заказ не зарегистрирован в системе начислений.
+func NotFound() Step {
+	return Step{kind: stepNotFound}
+}
+
+// RateLimited возвращает шаг с ответом 429 и заданным Retry-After.
+func RateLimited(retryAfter time.Duration) Step {
+	return Step{kind: stepRateLimit, retryAfter: retryAfter}
+}
+
+// Malformed возвращает шаг с ответом, который не удалось разобрать.
+func Malformed() Step {
+	return Step{kind: stepMalformed}
+}
+
+// Error возвращает шаг с произвольной ошибкой, например сетевой.
+func Error(err error) Step {
+	return Step{kind: stepError, err: err}
+}
+
+// Client сценарная реализация domain.AccrualClient.
+// Шаги сценария заказа выдаются по очереди, последний шаг повторяется.
+// Для заказов без сценария возвращается domain.ErrAccrualOrderNotFound.
+// Безопасен для одновременного использования несколькими воркерами.
+type Client struct {
+	mu      sync.Mutex
+	scripts map[string][]Step
+	calls   map[string]int
+}
+
+// New создает новый экземпляр Client без сценариев.
+func New() *Client {
+	return &Client{
+		scripts: make(map[string][]Step),
+		calls:   make(map[string]int),
+	}
+}
+
+// Script задает сценарий ответов для заказа, заменяя прежний. Счетчик вызовов заказа сбрасывается.
+func (c *Client) Script(orderNumber string, steps ...Step) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scripts[orderNumber] = steps
+	c.calls[orderNumber] = 0
+	return c
+}
+
+// Calls возвращает количество запросов по заказу.
+func (c *Client) Calls(orderNumber string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[orderNumber]
+}
+
+// GetOrderAccrual возвращает очередной ответ сценария заказа.
+func (c *Client) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.OrderAccrual, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	steps := c.scripts[orderNumber]
+	call := c.calls[orderNumber]
+	c.calls[orderNumber] = call + 1
+	c.mu.Unlock()
+
+	if len(steps) == 0 {
+		return nil, domain.ErrAccrualOrderNotFound
+	}
+
+	step := steps[min(call, len(steps)-1)]
+	switch step.kind {
+	case stepStatus:
+		return &domain.OrderAccrual{
+			Order:   orderNumber,
+			Status:  step.status,
+			Accrual: step.accrual,
+		}, nil
+	case stepNotFound:
+		return nil, domain.ErrAccrualOrderNotFound
+	case stepRateLimit:
+		return nil, &domain.AccrualRateLimitError{RetryAfter: step.retryAfter}
+	case stepMalformed:
+		return nil, fmt.Errorf("%w: %w", domain.ErrAccrualMalformedResponse, errors.New("unexpected end of JSON input"))
+	case stepError:
+		return nil, step.err
+	default:
+		return nil, fmt.Errorf("unknown step kind %d", step.kind)
+	}
+}
+
+// Проверка соответствия интерфейсу.
+var _ domain.AccrualClient = (*Client)(nil)
@@ -0,0 +1,191 @@
+package accrualmock
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/utils"
+)
+
+// rateLimitWindow окно, в котором считается лимит запросов.
+const rateLimitWindow = time.Minute
+
+// Config содержит настройки имитации системы начислений.
+type Config struct {
+	ProcessingDelay time.Duration // Время от регистрации заказа до окончательного статуса
+	Rules           []Rule        // Начальные правила вознаграждения
+	RateLimit       int           // Лимит запросов GET /api/orders/{number} в минуту (0 - без ограничения)
+	ErrorRate       float64       // Доля запросов, на которые отвечаем 500
+	SlowRate        float64       // Доля запросов, ответ на которые задерживается
+	SlowDelay       time.Duration // Задержка медленных ответов
+	AutoReward      domain.Money  // Начисление для неизвестных заказов с верным номером (0 - отвечать 204)
+}
+
+// Server HTTP-сервер, имитирующий систему начислений.
+type Server struct {
+	echo     *echo.Echo
+	store    *Store
+	validate *validator.Validate
+	config   Config
+	logger   *slog.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowCount int
+}
+
+// NewServer создает новый экземпляр Server.
+func NewServer(cfg Config, logger *slog.Logger) *Server {
+	s := &Server{
+		echo:     echo.New(),
+		store:    NewStore(cfg.ProcessingDelay, cfg.Rules),
+		validate: validator.New(),
+		config:   cfg,
+		logger: logger.With(
+			"package", "accrualmock",
+			"component", "Server",
+		),
+	}
+
+	s.echo.HideBanner = true
+	s.echo.Use(middleware.Recover())
+	s.echo.Use(s.chaos)
+
+	api := s.echo.Group("/api")
+	api.GET("/orders/:number", s.getOrder, s.rateLimit)
+	api.POST("/orders", s.registerOrder)
+	api.POST("/goods", s.registerRule)
+
+	return s
+}
+
+// Handler возвращает HTTP-обработчик сервера.
+func (s *Server) Handler() http.Handler {
+	return s.echo
+}
+
+// Store возвращает хранилище заказов и правил.
+func (s *Server) Store() *Store {
+	return s.store
+}
+
+// getOrder возвращает информацию о расчете начислений по заказу.
+func (s *Server) getOrder(c echo.Context) error {
+	number := c.Param("number")
+
+	if s.config.AutoReward > 0 && utils.ValidateLuhn(number) {
+		s.store.RegisterFixed(number, s.config.AutoReward)
+	}
+
+	accrual, found := s.store.Get(number)
+	if !found {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	return c.JSON(http.StatusOK, accrual)
+}
+
+// registerOrder принимает заказ к расчету.
+func (s *Server) registerOrder(c echo.Context) error {
+	var req OrderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if err := s.validate.Struct(req); err != nil || !utils.ValidateLuhn(req.Order) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if err := s.store.RegisterOrder(req); err != nil {
+		if errors.Is(err, ErrOrderExists) {
+			return c.NoContent(http.StatusConflict)
+		}
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("заказ принят к расчету", "номер заказа", req.Order)
+	return c.NoContent(http.StatusAccepted)
+}
+
+// registerRule регистрирует правило вознаграждения.
+func (s *Server) registerRule(c echo.Context) error {
+	var rule Rule
+	if err := c.Bind(&rule); err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if err := s.validate.Struct(rule); err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if err := s.store.AddRule(rule); err != nil {
+		if errors.Is(err, ErrRuleExists) {
+			return c.NoContent(http.StatusConflict)
+		}
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s.logger.Debug("правило вознаграждения зарегистрировано", "match", rule.Match)
+	return c.NoContent(http.StatusOK)
+}
+
+// rateLimit ограничивает число запросов в минуту и отвечает 429 так же, как настоящая система.
+func (s *Server) rateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.config.RateLimit <= 0 {
+			return next(c)
+		}
+
+		s.mu.Lock()
+		now := time.Now()
+		if now.Sub(s.windowStart) >= rateLimitWindow {
+			s.windowStart = now
+			s.windowCount = 0
+		}
+		s.windowCount++
+		exceeded := s.windowCount > s.config.RateLimit
+		retryAfter := s.windowStart.Add(rateLimitWindow).Sub(now)
+		s.mu.Unlock()
+
+		if !exceeded {
+			return next(c)
+		}
+
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+		return c.String(
+			http.StatusTooManyRequests,
+			fmt.Sprintf("No more than %d requests per minute allowed", s.config.RateLimit),
+		)
+	}
+}
+
+// chaos случайно отвечает 500 или задерживает ответ в заданной доле запросов.
+func (s *Server) chaos(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		//nolint:gosec // G404: криптостойкость для имитации сбоев не требуется
+		if s.config.ErrorRate > 0 && rand.Float64() < s.config.ErrorRate {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		//nolint:gosec // G404: криптостойкость для имитации сбоев не требуется
+		if s.config.SlowRate > 0 && rand.Float64() < s.config.SlowRate {
+			select {
+			case <-time.After(s.config.SlowDelay):
+			case <-c.Request().Context().Done():
+				return c.Request().Context().Err()
+			}
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,191 @@
+// Package accrualmock реализует в памяти API системы расчета начислений из SPECIFICATION.md
+// для локальной разработки и тестов без внешних зависимостей.
+package accrualmock
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// RewardType способ расчета вознаграждения по правилу.
+type RewardType string
+
+const (
+	// RewardPercent вознаграждение в процентах от стоимости товара.
+	RewardPercent RewardType = "%"
+	// RewardPoints фиксированное вознаграждение в баллах.
+	RewardPoints RewardType = "pt"
+
+	percentBase = 100
+)
+
+var (
+	// ErrRuleExists ошибка правило для такого ключа поиска уже зарегистрировано.
+	ErrRuleExists = errors.New("правило уже зарегистрировано")
+	// ErrOrderExists ошибка заказ уже принят к расчету.
+	ErrOrderExists = errors.New("заказ уже принят к расчету")
+)
+
+// Rule правило вознаграждения за товары, в описании которых встречается Match.
+type Rule struct {
+	Match      string     `json:"match" validate:"required"`
+	Reward     float64    `json:"reward" validate:"gt=0"`
+	RewardType RewardType `json:"reward_type" validate:"oneof=% pt"`
+}
+
+// Good товар в составе заказа.
+type Good struct {
+	Description string       `json:"description" validate:"required"`
+	Price       domain.Money `json:"price" validate:"gte=0"`
+}
+
+// OrderRequest запрос на регистрацию заказа для расчета.
+type OrderRequest struct {
+	Order string `json:"order" validate:"required"`
+	Goods []Good `json:"goods" validate:"dive"`
+}
+
+// order заказ, принятый к расчету.
+type order struct {
+	goods        []Good
+	registeredAt time.Time
+	fixed        *domain.Money // начисление, заданное без правил (для автоматически зарегистрированных заказов)
+}
+
+// Store хранит правила и заказы в памяти.
+// Статус заказа вычисляется по времени с момента регистрации: первую половину задержки
+// заказ находится в REGISTERED, вторую - в PROCESSING, затем получает окончательный статус.
+type Store struct {
+	mu              sync.RWMutex
+	rules           []Rule
+	orders          map[string]*order
+	processingDelay time.Duration
+	now             func() time.Time
+}
+
+// NewStore создает новый экземпляр Store с начальными правилами.
+func NewStore(processingDelay time.Duration, rules []Rule) *Store {
+	return &Store{
+		rules:           append([]Rule(nil), rules...),
+		orders:          make(map[string]*order),
+		processingDelay: processingDelay,
+		now:             time.Now,
+	}
+}
+
+// AddRule регистрирует правило вознаграждения.
+func (s *Store) AddRule(rule Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.rules {
+		if r.Match == rule.Match {
+			return ErrRuleExists
+		}
+	}
+
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+// RegisterOrder принимает заказ к расчету.
+func (s *Store) RegisterOrder(req OrderRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orders[req.Order]; exists {
+		return ErrOrderExists
+	}
+
+	s.orders[req.Order] = &order{
+		goods:        req.Goods,
+		registeredAt: s.now(),
+	}
+	return nil
+}
+
+// RegisterFixed принимает заказ к расчету с заранее известным начислением.
+// Повторная регистрация не меняет заказ.
+func (s *Store) RegisterFixed(number string, accrual domain.Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orders[number]; exists {
+		return
+	}
+
+	s.orders[number] = &order{
+		registeredAt: s.now(),
+		fixed:        &accrual,
+	}
+}
+
+// Get возвращает текущее состояние расчета по заказу. Второе значение false, если заказ не зарегистрирован.
+func (s *Store) Get(number string) (domain.OrderAccrual, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, exists := s.orders[number]
+	if !exists {
+		return domain.OrderAccrual{}, false
+	}
+
+	result := domain.OrderAccrual{Order: number}
+	age := s.now().Sub(o.registeredAt)
+	switch {
+	case age < s.processingDelay/2:
+		result.Status = domain.OrderStatusRegistered
+	case age < s.processingDelay:
+		result.Status = domain.OrderStatusProcessing
+	default:
+		accrual, matched := s.calculate(o)
+		if !matched {
+			result.Status = domain.OrderStatusInvalid
+			break
+		}
+		result.Status = domain.OrderStatusProcessed
+		if accrual > 0 {
+			result.Accrual = &accrual
+		}
+	}
+
+	return result, true
+}
+
+// calculate рассчитывает начисление по заказу. Второе значение false, если ни одно правило не подошло.
+func (s *Store) calculate(o *order) (domain.Money, bool) {
+	if o.fixed != nil {
+		return *o.fixed, true
+	}
+
+	var total domain.Money
+	matched := false
+	for _, good := range o.goods {
+		for _, rule := range s.rules {
+			if !strings.Contains(good.Description, rule.Match) {
+				continue
+			}
+			matched = true
+			total += rule.apply(good.Price)
+		}
+	}
+
+	return total, matched
+}
+
+// apply рассчитывает вознаграждение по правилу за товар указанной стоимости.
+func (r Rule) apply(price domain.Money) domain.Money {
+	switch r.RewardType {
+	case RewardPercent:
+		return domain.Money(math.Round(float64(price) * r.Reward / percentBase))
+	case RewardPoints:
+		return domain.Money(math.Round(r.Reward * domain.KopPerRuble))
+	default:
+		return 0
+	}
+}
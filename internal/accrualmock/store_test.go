@@ -0,0 +1,227 @@
+package accrualmock
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// fakeClock часы, которые двигает тест.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestStore создает Store с правилами и часами, которые двигает тест.
+func newTestStore(delay time.Duration, rules ...Rule) (*Store, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewStore(delay, rules)
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestStoreTimeline(t *testing.T) {
+	const delay = 10 * time.Second
+
+	tests := []struct {
+		name        string
+		goods       []Good
+		wantFinal   domain.OrderStatus
+		wantAccrual *domain.Money
+	}{
+		{
+			name:        "matched rule",
+			goods:       []Good{{Description: "Чайник Bork", Price: 700000}},
+			wantFinal:   domain.OrderStatusProcessed,
+			wantAccrual: moneyPtr(70000),
+		},
+		{
+			name:      "no rule matched",
+			goods:     []Good{{Description: "Утюг", Price: 500000}},
+			wantFinal: domain.OrderStatusInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore(delay, Rule{Match: "Bork", Reward: 10, RewardType: RewardPercent})
+			if err := store.RegisterOrder(OrderRequest{Order: "12345678903", Goods: tt.goods}); err != nil {
+				t.Fatalf("RegisterOrder: %v", err)
+			}
+
+			steps := []struct {
+				after time.Duration
+				want  domain.OrderStatus
+			}{
+				{0, domain.OrderStatusRegistered},
+				{delay/2 - time.Nanosecond, domain.OrderStatusRegistered},
+				{time.Nanosecond, domain.OrderStatusProcessing},
+				{delay/2 - time.Nanosecond, domain.OrderStatusProcessing},
+				{time.Nanosecond, tt.wantFinal},
+				{time.Hour, tt.wantFinal},
+			}
+			for i, step := range steps {
+				clock.advance(step.after)
+				got, found := store.Get("12345678903")
+				if !found {
+					t.Fatalf("step %d: order not found", i)
+				}
+				if got.Status != step.want {
+					t.Fatalf("step %d: status = %s, want %s", i, got.Status, step.want)
+				}
+				if step.want != tt.wantFinal {
+					if got.Accrual != nil {
+						t.Errorf("step %d: accrual = %v before final status", i, *got.Accrual)
+					}
+					continue
+				}
+				if !equalMoney(got.Accrual, tt.wantAccrual) {
+					t.Errorf("step %d: accrual = %v, want %v", i, got.Accrual, tt.wantAccrual)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreUnknownOrder(t *testing.T) {
+	store, _ := newTestStore(time.Second)
+	if _, found := store.Get("12345678903"); found {
+		t.Error("unknown order found")
+	}
+}
+
+func TestRuleMath(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		goods []Good
+		want  domain.Money
+	}{
+		{
+			name:  "percent of price",
+			rules: []Rule{{Match: "Bork", Reward: 10, RewardType: RewardPercent}},
+			goods: []Good{{Description: "Чайник Bork", Price: 700000}},
+			want:  70000,
+		},
+		{
+			name:  "fractional percent rounds to kopeck",
+			rules: []Rule{{Match: "Bork", Reward: 7.5, RewardType: RewardPercent}},
+			goods: []Good{{Description: "Bork", Price: 12345}}, // 925.875 коп.
+			want:  926,
+		},
+		{
+			name:  "points are rubles",
+			rules: []Rule{{Match: "Bork", Reward: 12.34, RewardType: RewardPoints}},
+			goods: []Good{{Description: "Bork", Price: 100}},
+			want:  1234,
+		},
+		{
+			name: "rules and goods add up",
+			rules: []Rule{
+				{Match: "Bork", Reward: 10, RewardType: RewardPercent},
+				{Match: "Чайник", Reward: 5, RewardType: RewardPoints},
+			},
+			goods: []Good{
+				{Description: "Чайник Bork", Price: 10000}, // 1000 + 500
+				{Description: "Миксер Bork", Price: 20000}, // 2000
+				{Description: "Утюг", Price: 30000},        // нет правил
+			},
+			want: 3500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore(time.Second, tt.rules...)
+			if err := store.RegisterOrder(OrderRequest{Order: "12345678903", Goods: tt.goods}); err != nil {
+				t.Fatalf("RegisterOrder: %v", err)
+			}
+			clock.advance(time.Second)
+
+			got, _ := store.Get("12345678903")
+			if got.Status != domain.OrderStatusProcessed || !equalMoney(got.Accrual, &tt.want) {
+				t.Errorf("got %s %v, want %s %v", got.Status, got.Accrual, domain.OrderStatusProcessed, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreRegisterDuplicate(t *testing.T) {
+	store, _ := newTestStore(time.Second)
+	req := OrderRequest{Order: "12345678903", Goods: []Good{{Description: "Bork", Price: 100}}}
+
+	if err := store.RegisterOrder(req); err != nil {
+		t.Fatalf("RegisterOrder: %v", err)
+	}
+	if err := store.RegisterOrder(req); !errors.Is(err, ErrOrderExists) {
+		t.Errorf("second RegisterOrder = %v, want %v", err, ErrOrderExists)
+	}
+}
+
+func TestServerRegisterOrderConflict(t *testing.T) {
+	server := NewServer(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	body := `{"order": "12345678903", "goods": [{"description": "Чайник Bork", "price": 7000}]}`
+
+	for i, want := range []int{http.StatusAccepted, http.StatusConflict} {
+		req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	const limit = 2
+	server := NewServer(Config{RateLimit: limit}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/orders/12345678903", nil))
+		return rec
+	}
+
+	for i := range limit {
+		if rec := get(); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, http.StatusNoContent)
+		}
+	}
+
+	rec := get()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > int(rateLimitWindow/time.Second) {
+		t.Errorf("Retry-After = %q, want 1..%d seconds", rec.Header().Get("Retry-After"), int(rateLimitWindow/time.Second))
+	}
+	if !strings.Contains(rec.Body.String(), strconv.Itoa(limit)) {
+		t.Errorf("body = %q, want the limit mentioned", rec.Body.String())
+	}
+}
+
+func moneyPtr(kop int64) *domain.Money {
+	m := domain.Money(kop)
+	return &m
+}
+
+func equalMoney(a, b *domain.Money) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -12,16 +12,31 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
+	"gophermart/internal/auth"
+	"gophermart/internal/domain"
 	"gophermart/internal/handlers"
+	"gophermart/internal/metrics"
 	"gophermart/internal/repository"
 	"gophermart/internal/service"
+	"gophermart/internal/tracing"
 	"gophermart/internal/worker"
 )
 
 const (
-	defaultWorkerCount = 2
-	defaultTimeout     = 10 * time.Second
+	defaultWorkerCount    = 2
+	defaultTimeout        = 10 * time.Second
+	defaultIdempotencyTTL = 24 * time.Hour
+	defaultAccessTokenTTL = 15 * time.Minute
+	defaultRefreshTTL     = 30 * 24 * time.Hour
+	serviceName           = "gophermart"
+	// interruptGracePeriod время на выход воркеров после прерывания обработки заказов.
+	interruptGracePeriod = 2 * time.Second
+	// tracingFlushTimeout время на отправку накопленных span при остановке.
+	tracingFlushTimeout = 5 * time.Second
+	// defaultIdempotencyLease время, на которое выполняющийся запрос занимает ключ идемпотентности.
+	defaultIdempotencyLease = time.Minute
 )
 
 // App представляет основную структуру приложения.
@@ -31,13 +46,37 @@ type App struct {
 	userHandler    *handlers.UserHandler
 	orderHandler   *handlers.OrderHandler
 	balanceHandler *handlers.BalanceHandler
+	adminHandler   *handlers.AdminHandler
+	auditHandler   *handlers.AuditHandler
+	statusHandler  *handlers.StatusHandler
+	healthHandler  *handlers.HealthHandler
+	health         *service.HealthService
+	jwksHandler    *handlers.JWKSHandler
 	accrualWorker  *worker.AccrualWorker
+	orderListener  *worker.OrderListener
+	idempotency    domain.IdempotencyRepository
+	sessions       domain.SessionRepository
+	keys           *auth.KeySet
+	metrics        *metrics.Prometheus
+	stopTracing    func(context.Context) error
+	stopClaims     context.CancelFunc // прекращает захват новых заказов воркерами
+	stopProcessing context.CancelFunc // прерывает обработку текущих заказов
 	config         Config
 	wg             sync.WaitGroup // добавляем WaitGroup для ожидания завершения горутин
 }
 
 // New создает новый экземпляр приложения.
 func New(ctx context.Context, cfg Config) (*App, error) {
+	// Трассировка настраивается первой, чтобы span получали все компоненты
+	stopTracing, tracingErr := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  serviceName,
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.OTLPEndpoint,
+	})
+	if tracingErr != nil {
+		return nil, fmt.Errorf("failed to initialize tracing: %w", tracingErr)
+	}
+
 	// Инициализация базы данных
 	db, dbErr := NewDB(ctx, cfg.DatabaseURI)
 	if dbErr != nil {
@@ -49,39 +88,155 @@ func New(ctx context.Context, cfg Config) (*App, error) {
 		return nil, fmt.Errorf("failed to apply migrations: %w", migrateErr)
 	}
 
+	// Метрики приложения, пула соединений и очереди заказов
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db.DB, serviceName)
+
 	// Инициализация репозиториев
 	userRepo := repository.NewUserRepo(db)
 	orderRepo := repository.NewOrderRepo(db, slog.Default())
 	balanceRepo := repository.NewBalanceRepo(db, slog.Default())
+	idempotencyRepo := repository.NewIdempotencyRepo(db, slog.Default())
+	sessionRepo := repository.NewSessionRepo(db, slog.Default())
+	loginThrottleRepo := repository.NewLoginThrottleRepo(db)
+	auditRepo := repository.NewAuditRepo(db, slog.Default())
+	appMetrics.RegisterOrderQueue(orderRepo, slog.Default())
+
+	// Набор ключей подписи JWT
+	keys, keysErr := auth.NewKeySet(cfg.JWTSecret, cfg.JWTSecretKID, cfg.JWTKeysDir)
+	if keysErr != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", keysErr)
+	}
+	slog.Info("JWT signing key", "kid", keys.Current().ID, "alg", keys.Current().Method.Alg())
+
+	// Хеширование и политика паролей
+	hasher, hasherErr := auth.NewPasswordHasher(cfg.PasswordHashAlgorithm)
+	if hasherErr != nil {
+		return nil, fmt.Errorf("failed to initialize password hasher: %w", hasherErr)
+	}
+	passwordPolicy, policyErr := service.NewPasswordPolicy(service.PasswordPolicyConfig{
+		MinLength:     cfg.PasswordMinLength,
+		MinClasses:    cfg.PasswordMinClasses,
+		BlocklistFile: cfg.PasswordBlocklistFile,
+	})
+	if policyErr != nil {
+		return nil, fmt.Errorf("failed to initialize password policy: %w", policyErr)
+	}
 
 	// Инициализация сервисов
-	userService := service.NewUserService(userRepo, cfg.JWTSecret, cfg.JWTExpirationPeriod)
-	orderService := service.NewOrderService(orderRepo)
-	balanceService := service.NewBalanceService(balanceRepo, slog.Default())
-	accrualService := service.NewAccrualService(cfg.AccrualSystemAddress)
+	auditor := service.NewAuditor(auditRepo, slog.Default())
+	accessTTL := cfg.JWTExpirationPeriod
+	if accessTTL <= 0 {
+		accessTTL = defaultAccessTokenTTL
+	}
+	refreshTTL := cfg.RefreshTokenTTL
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTTL
+	}
+	userService := service.NewUserService(
+		userRepo,
+		sessionRepo,
+		loginThrottleRepo,
+		keys,
+		hasher,
+		passwordPolicy,
+		auditor,
+		service.UserConfig{
+			AccessTTL:  accessTTL,
+			RefreshTTL: refreshTTL,
+			LoginThrottle: service.LoginThrottleConfig{
+				MaxFailures:   cfg.LoginMaxFailures,
+				IPMaxFailures: cfg.LoginIPMaxFailures,
+				Lockout:       cfg.LoginLockout,
+				MaxLockout:    cfg.LoginMaxLockout,
+			},
+		},
+	)
+	orderService := service.NewOrderService(orderRepo, auditor)
+	balanceService := service.NewBalanceService(balanceRepo, auditor, appMetrics, slog.Default())
+	adminService := service.NewAdminService(
+		userRepo,
+		orderRepo,
+		balanceRepo,
+		sessionRepo,
+		auditor,
+		appMetrics,
+		slog.Default(),
+	)
+	auditService := service.NewAuditService(auditRepo)
+	accrualService := service.NewAccrualService(service.AccrualConfig{
+		BaseURL:   cfg.AccrualSystemAddress,
+		RateLimit: cfg.AccrualRateLimit,
+		CircuitBreaker: service.CircuitBreakerConfig{
+			FailureThreshold:  cfg.AccrualBreakerFailures,
+			SlowCallThreshold: cfg.AccrualBreakerSlowCall,
+			OpenTimeout:       cfg.AccrualBreakerOpenTimeout,
+		},
+	}, appMetrics, slog.Default())
+
+	// Слушатель уведомлений о новых заказах будит воркеры без ожидания опроса
+	orderListener := worker.NewOrderListener(cfg.DatabaseURI, slog.Default())
 
 	// Создаем воркер для обработки начислений
 	accrualWorker := worker.NewAccrualWorker(
 		slog.Default(),
 		orderRepo,
 		accrualService,
-		defaultWorkerCount, // количество воркеров
-		defaultTimeout,
-		0, // без задержки между попытками
+		auditor,
+		appMetrics,
+		worker.Config{
+			WorkerCount:  defaultWorkerCount,
+			PollInterval: cfg.AccrualPollInterval,
+			MaxAttempts:  cfg.AccrualMaxAttempts,
+			MaxAge:       cfg.AccrualMaxAge,
+			Wakeup:       orderListener.Wakeup(),
+		},
 	)
 
 	// Инициализация обработчиков
 	userHandler := handlers.NewUserHandler(userService)
 	orderHandler := handlers.NewOrderHandler(orderService)
 	balanceHandler := handlers.NewBalanceHandler(balanceService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	statusHandler := handlers.NewStatusHandler(accrualService)
+
+	// Проверки готовности: база данных, актуальность миграций, система начислений
+	checkMigrations, checkErr := migrationsCheck(db.DB, cfg.MigrationsDir)
+	if checkErr != nil {
+		return nil, fmt.Errorf("failed to initialize migrations check: %w", checkErr)
+	}
+	healthService := service.NewHealthService([]domain.HealthCheck{
+		{Name: "database", Check: db.PingContext},
+		{Name: "migrations", Check: checkMigrations},
+		{Name: "accrual", Check: accrualService.CheckReachable},
+	}, cfg.HealthCheckTimeout, slog.Default())
+	healthHandler := handlers.NewHealthHandler(healthService)
+	jwksHandler := handlers.NewJWKSHandler(keys)
 
 	// Инициализация Echo
 	e := echo.New()
 	e.Validator = NewValidator()
 
+	// IP-адрес клиента нужен для ограничения попыток входа; заголовкам прокси доверяем только явно
+	if cfg.TrustProxyHeaders {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
 	// Промежуточное ПО (middleware)
+	e.Use(otelecho.Middleware(serviceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/healthz", "/readyz":
+			return true
+		default:
+			return false
+		}
+	})))
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	e.Use(MetricsMiddleware(appMetrics))
 
 	app := &App{
 		echo:           e,
@@ -89,7 +244,19 @@ func New(ctx context.Context, cfg Config) (*App, error) {
 		userHandler:    userHandler,
 		orderHandler:   orderHandler,
 		balanceHandler: balanceHandler,
+		adminHandler:   adminHandler,
+		auditHandler:   auditHandler,
+		statusHandler:  statusHandler,
+		healthHandler:  healthHandler,
+		health:         healthService,
+		jwksHandler:    jwksHandler,
 		accrualWorker:  accrualWorker,
+		orderListener:  orderListener,
+		idempotency:    idempotencyRepo,
+		sessions:       sessionRepo,
+		keys:           keys,
+		metrics:        appMetrics,
+		stopTracing:    stopTracing,
 		config:         cfg,
 	}
 
@@ -99,13 +266,28 @@ func New(ctx context.Context, cfg Config) (*App, error) {
 	return app, nil
 }
 
-// Start запускает приложение.
+// Start запускает приложение и блокируется до отмены ctx или ошибки HTTP-сервера,
+// после чего выполняет поэтапную остановку.
 func (a *App) Start(ctx context.Context, address string) error {
+	// Воркеры живут в собственных контекстах: сигнал остановки сначала закрывает HTTP-сервер,
+	// а воркеры останавливаются отдельными этапами в Shutdown
+	claimCtx, stopClaims := context.WithCancel(context.Background())
+	processCtx, stopProcessing := context.WithCancel(context.Background())
+	a.stopClaims = stopClaims
+	a.stopProcessing = stopProcessing
+
 	// Запускаем воркер начислений в отдельной горутине
 	a.wg.Add(1)
 	go func() {
 		defer a.wg.Done()
-		a.accrualWorker.Start(ctx)
+		a.accrualWorker.Start(claimCtx, processCtx)
+	}()
+
+	// Запускаем слушатель новых заказов
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.orderListener.Start(claimCtx)
 	}()
 
 	// Запускаем HTTP-сервер в фоне
@@ -115,6 +297,7 @@ func (a *App) Start(ctx context.Context, address string) error {
 	}()
 
 	// Ожидаем либо завершения контекста, либо ошибки сервера
+	var startErr error
 	select {
 	case <-ctx.Done():
 		slog.Info("shutting down server...")
@@ -122,51 +305,125 @@ func (a *App) Start(ctx context.Context, address string) error {
 	case err := <-serverErr:
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("server error", "error", err)
-			return err
+			startErr = err
 		}
 	}
 
-	// Создаём контекст с таймаутом для graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	if err := a.Shutdown(shutdownCtx); err != nil {
+	if err := a.Shutdown(context.Background()); err != nil {
 		slog.Error("failed to shutdown application", "error", err)
 	}
 
-	return nil
+	return startErr
 }
 
-// Shutdown выполняет корректное завершение работы приложения.
+// Shutdown выполняет поэтапную остановку приложения. Каждый этап ограничен своим таймаутом из Config:
+//  1. снятие готовности и пауза, чтобы балансировщик перестал направлять запросы;
+//  2. закрытие слушателя и ожидание выполняющихся HTTP-запросов;
+//  3. прекращение захвата новых заказов воркерами;
+//  4. ожидание текущих заказов, по истечении таймаута их обработка прерывается;
+//  5. закрытие пула соединений с базой данных и отправка накопленных span.
+//
+// База данных закрывается последней, поэтому ни HTTP-запросы, ни воркеры не обращаются к закрытому пулу.
 func (a *App) Shutdown(ctx context.Context) error {
-	// Ждем завершения всех воркеров
-	shutdownComplete := make(chan struct{})
+	// Этап 1: снимаем готовность
+	a.health.StartShutdown()
+	if delay := a.config.ShutdownReadinessDelay; delay > 0 {
+		slog.Info("shutdown: waiting for load balancer to stop routing", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	// Этап 2: перестаем принимать соединения и дожидаемся выполняющихся запросов
+	httpCtx, cancelHTTP := context.WithTimeout(ctx, a.phaseTimeout(a.config.ShutdownHTTPTimeout))
+	defer cancelHTTP()
+	if err := a.echo.Shutdown(httpCtx); err != nil {
+		slog.Warn("shutdown: http drain timeout exceeded, closing remaining connections", "error", err)
+		if closeErr := a.echo.Close(); closeErr != nil {
+			slog.Error("shutdown: failed to close http server", "error", closeErr)
+		}
+	} else {
+		slog.Info("shutdown: http requests drained")
+	}
+
+	// Этап 3: воркеры больше не захватывают заказы, слушатель уведомлений отключается
+	if a.stopClaims != nil {
+		a.stopClaims()
+	}
+
+	// Этап 4: ждем, пока воркеры доведут до конца текущие заказы
+	workersDone := make(chan struct{})
 	go func() {
 		a.wg.Wait()
-		close(shutdownComplete)
+		close(workersDone)
 	}()
 
-	// Ожидаем либо завершения всех воркеров, либо таймаута контекста
+	workerCtx, cancelWorkers := context.WithTimeout(ctx, a.phaseTimeout(a.config.ShutdownWorkerTimeout))
+	defer cancelWorkers()
 	select {
-	case <-shutdownComplete:
-		slog.Info("all workers completed")
-	case <-ctx.Done():
-		slog.Warn("shutdown timeout exceeded, some workers may not have completed")
+	case <-workersDone:
+		slog.Info("shutdown: all workers completed")
+	case <-workerCtx.Done():
+		slog.Warn("shutdown: worker drain timeout exceeded, interrupting order processing")
+		if a.stopProcessing != nil {
+			a.stopProcessing()
+		}
+		// Прерванные заказы вернутся в очередь после истечения аренды
+		select {
+		case <-workersDone:
+		case <-time.After(interruptGracePeriod):
+			slog.Warn("shutdown: some workers may not have completed")
+		}
+	}
+	if a.stopProcessing != nil {
+		a.stopProcessing()
 	}
 
-	if err := a.db.Close(); err != nil {
-		return fmt.Errorf("failed to close database connection: %w", err)
+	// Этап 5: закрываем базу данных (Close ждет завершения начатых запросов) и отправляем накопленные span
+	dbClosed := make(chan error, 1)
+	go func() {
+		dbClosed <- a.db.Close()
+	}()
+	select {
+	case err := <-dbClosed:
+		if err != nil {
+			return fmt.Errorf("failed to close database connection: %w", err)
+		}
+		slog.Info("shutdown: database connection closed")
+	case <-time.After(a.phaseTimeout(a.config.ShutdownDBTimeout)):
+		slog.Warn("shutdown: database close timeout exceeded")
 	}
 
-	if err := a.echo.Shutdown(ctx); err != nil {
-		return fmt.Errorf("failed to shutdown http server: %w", err)
+	tracingCtx, cancelTracing := context.WithTimeout(ctx, tracingFlushTimeout)
+	defer cancelTracing()
+	if err := a.stopTracing(tracingCtx); err != nil {
+		return fmt.Errorf("failed to shutdown tracing: %w", err)
 	}
 
 	return nil
 }
 
+// phaseTimeout возвращает таймаут этапа остановки или значение по умолчанию.
+func (a *App) phaseTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
 // setupRoutes настраивает маршруты приложения.
 func (a *App) setupRoutes() {
+	// Проверки живости и готовности для оркестратора
+	a.echo.GET("/healthz", a.healthHandler.Liveness)
+	a.echo.GET("/readyz", a.healthHandler.Readiness)
+
+	// Метрики в формате Prometheus
+	a.echo.GET("/metrics", echo.WrapHandler(a.metrics.Handler()))
+
+	// Открытые ключи для проверки токенов другими сервисами
+	a.echo.GET("/.well-known/jwks.json", a.jwksHandler.GetJWKS)
+
 	// Группа API
 	api := a.echo.Group("/api")
 
@@ -176,16 +433,52 @@ func (a *App) setupRoutes() {
 	// Публичные маршруты
 	user.POST("/register", a.userHandler.Register)
 	user.POST("/login", a.userHandler.Authenticate)
+	user.POST("/token/refresh", a.userHandler.Refresh)
 
 	// Защищенные маршруты
-	protected := user.Group("", JWTMiddleware(a.config.JWTSecret))
+	protected := user.Group("", JWTMiddleware(a.keys, a.sessions))
+
+	// Маршруты сессий
+	protected.POST("/logout", a.userHandler.Logout)
+	protected.POST("/logout-all", a.userHandler.LogoutAll)
+	protected.PUT("/password", a.userHandler.ChangePassword)
+
+	// Повторы запросов с одинаковым Idempotency-Key не создают новых заказов и списаний
+	idempotencyTTL := a.config.IdempotencyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = defaultIdempotencyTTL
+	}
+	idempotencyLease := a.config.IdempotencyLease
+	if idempotencyLease <= 0 {
+		idempotencyLease = defaultIdempotencyLease
+	}
+	idempotent := IdempotencyMiddleware(a.idempotency, idempotencyTTL, idempotencyLease, slog.Default())
 
 	// Маршруты заказов
-	protected.POST("/orders", a.orderHandler.Register)
+	protected.POST("/orders", a.orderHandler.Register, idempotent)
 	protected.GET("/orders", a.orderHandler.GetOrders)
+	protected.GET("/orders/:number", a.orderHandler.GetOrder)
 
 	// Маршруты баланса
 	protected.GET("/balance", a.balanceHandler.GetBalance)
-	protected.POST("/balance/withdraw", a.balanceHandler.Withdraw)
+	protected.POST("/balance/withdraw", a.balanceHandler.Withdraw, idempotent)
 	protected.GET("/withdrawals", a.balanceHandler.GetWithdrawals)
+	protected.GET("/balance/history", a.balanceHandler.GetHistory)
+
+	// Маршруты службы поддержки
+	admin := api.Group("/admin", JWTMiddleware(a.keys, a.sessions), RequireRole(domain.RoleAdmin))
+	admin.GET("/users", a.adminHandler.SearchUsers)
+	admin.GET("/users/:id/orders", a.adminHandler.GetUserOrders)
+	admin.GET("/users/:id/withdrawals", a.adminHandler.GetUserWithdrawals)
+	admin.GET("/users/:id/balance", a.adminHandler.GetUserBalance)
+	admin.GET("/users/:id/adjustments", a.adminHandler.GetUserAdjustments)
+	admin.POST("/users/:id/adjustments", a.adminHandler.AdjustBalance)
+	admin.POST("/users/:id/withdrawals/:withdrawal_id/reverse", a.adminHandler.ReverseWithdrawal)
+	admin.POST("/users/:id/block", a.adminHandler.BlockUser)
+	admin.POST("/users/:id/unblock", a.adminHandler.UnblockUser)
+	admin.GET("/audit", a.auditHandler.FindEvents)
+	admin.GET("/audit/verify", a.auditHandler.VerifyChain)
+
+	// Служебные маршруты: состояние внешних зависимостей содержит текст их ошибок
+	admin.GET("/status/accrual", a.statusHandler.GetAccrualStatus)
 }
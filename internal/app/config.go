@@ -6,10 +6,38 @@ import (
 
 // Config представляет конфигурацию приложения.
 type Config struct {
-	DatabaseURI          string        // URI подключения к базе данных
-	MigrationsDir        string        // Директория с миграциями
-	RunAddress           string        // Адрес и порт для запуска сервера
-	AccrualSystemAddress string        // Адрес системы расчета начислений
-	JWTSecret            string        // Секретный ключ для подписи JWT токенов
-	JWTExpirationPeriod  time.Duration // Период действия JWT токена
+	DatabaseURI               string        // URI подключения к базе данных
+	MigrationsDir             string        // Директория с миграциями
+	RunAddress                string        // Адрес и порт для запуска сервера
+	AccrualSystemAddress      string        // Адрес системы расчета начислений
+	AccrualRateLimit          float64       // Темп запросов к системе начислений в секунду (0 - без ограничения)
+	JWTSecret                 string        // Секретный ключ для подписи JWT токенов HS256
+	JWTSecretKID              string        // Идентификатор (kid) ключа JWTSecret (пусто - выводится из секрета)
+	JWTKeysDir                string        // Каталог с PEM-ключами подписи JWT (RS256, EdDSA)
+	JWTExpirationPeriod       time.Duration // Период действия JWT (access) токена
+	RefreshTokenTTL           time.Duration // Период действия refresh-токена
+	LoginMaxFailures          int           // Неудачных входов в учетную запись до блокировки
+	LoginIPMaxFailures        int           // Неудачных входов с одного IP-адреса до блокировки
+	LoginLockout              time.Duration // Длительность первой блокировки входа
+	LoginMaxLockout           time.Duration // Максимальная длительность блокировки входа
+	TrustProxyHeaders         bool          // Определять IP-адрес клиента по X-Forwarded-For
+	PasswordMinLength         int           // Минимальная длина пароля
+	PasswordMinClasses        int           // Минимальное число классов символов в пароле
+	PasswordBlocklistFile     string        // Файл с запрещенными паролями
+	PasswordHashAlgorithm     string        // Алгоритм хеширования новых паролей (bcrypt, argon2id)
+	IdempotencyTTL            time.Duration // Время хранения ответов по ключу идемпотентности
+	IdempotencyLease          time.Duration // Время, на которое выполняющийся запрос занимает ключ идемпотентности
+	AccrualBreakerFailures    int           // Число ошибок подряд, после которого цепь размыкается
+	AccrualBreakerSlowCall    time.Duration // Запросы дольше этого времени считаются ошибкой
+	AccrualBreakerOpenTimeout time.Duration // Время в разомкнутом состоянии до пробного запроса
+	AccrualMaxAttempts        int           // Число опросов системы начислений, после которого заказ получает INVALID
+	AccrualMaxAge             time.Duration // Возраст заказа, после которого опрос прекращается и заказ получает INVALID
+	AccrualPollInterval       time.Duration // Интервал страховочного опроса очереди заказов
+	TracingExporter           string        // Экспортер трассировки: none, otlp, stdout
+	OTLPEndpoint              string        // URL коллектора OTLP/HTTP для экспорта трассировки
+	HealthCheckTimeout        time.Duration // Таймаут каждой проверки готовности
+	ShutdownReadinessDelay    time.Duration // Пауза после снятия готовности до закрытия слушателя
+	ShutdownHTTPTimeout       time.Duration // Время на завершение выполняющихся HTTP-запросов
+	ShutdownWorkerTimeout     time.Duration // Время на завершение текущих заказов воркерами
+	ShutdownDBTimeout         time.Duration // Время на закрытие пула соединений с базой данных
 }
@@ -0,0 +1,252 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	// IdempotencyKeyHeader заголовок, по которому клиент помечает повторы одного запроса.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader заголовок, которым помечается ответ, восстановленный из хранилища.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyRecorder накапливает ответ обработчика, не отправляя его клиенту.
+// Клиент получает ответ только после того, как он сохранен для повторов.
+type idempotencyRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// newIdempotencyRecorder создает новый экземпляр idempotencyRecorder.
+func newIdempotencyRecorder() *idempotencyRecorder {
+	return &idempotencyRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+// Header возвращает заголовки накапливаемого ответа.
+func (r *idempotencyRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader запоминает статус ответа.
+func (r *idempotencyRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+// Write записывает тело ответа в буфер.
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// flush отправляет накопленный ответ клиенту.
+func (r *idempotencyRecorder) flush(resp *echo.Response) error {
+	for name, values := range r.header {
+		resp.Header()[name] = values
+	}
+	resp.WriteHeader(r.status)
+	_, err := resp.Write(r.body.Bytes())
+	return err
+}
+
+// requestHash вычисляет отпечаток запроса для сравнения повторов с исходным запросом.
+func requestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IdempotencyMiddleware создает middleware для обработки заголовка Idempotency-Key.
+// Первый ответ (статус и тело) сохраняется по паре пользователь+ключ на время ttl,
+// повторы получают сохраненный ответ, а повтор с другим телом запроса получает 422.
+// Клиент получает ответ только после его сохранения: если сохранить не удалось, он получает 500,
+// а ключ остается занятым, чтобы повтор не выполнил операцию второй раз.
+// Ответы 5xx и паника обработчика освобождают ключ, чтобы клиент мог повторить запрос после сбоя.
+// Пока запрос выполняется, ключ арендован на lease и аренда продлевается; если процесс упал,
+// не сохранив ответ, после окончания аренды повтор выполняется заново.
+// Должен подключаться после JWTMiddleware, так как использует user_id из контекста.
+func IdempotencyMiddleware(
+	repo domain.IdempotencyRepository,
+	ttl time.Duration,
+	lease time.Duration,
+	logger *slog.Logger,
+) echo.MiddlewareFunc {
+	logger = logger.With(
+		"package", "app",
+		"component", "IdempotencyMiddleware",
+	)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "Слишком длинный Idempotency-Key")
+			}
+
+			userID, ok := c.Get("user_id").(int)
+			if !ok {
+				return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+			}
+
+			// Читаем тело запроса и возвращаем его обратно для обработчика
+			body, readErr := io.ReadAll(c.Request().Body)
+			if readErr != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса (не удалось прочитать тело запроса)")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			lockedUntil := now.Add(lease)
+			record := &domain.IdempotencyRecord{
+				UserID:      userID,
+				Key:         key,
+				RequestHash: requestHash(c.Request().Method, c.Path(), body),
+				ExpiresAt:   now.Add(ttl),
+				LockedUntil: &lockedUntil,
+			}
+
+			ctx := c.Request().Context()
+			stored, reserved, reserveErr := repo.Reserve(ctx, record)
+			if reserveErr != nil {
+				logger.Error("не удалось занять ключ идемпотентности", "user_id", userID, "error", reserveErr)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+			}
+
+			if !reserved {
+				return replayIdempotent(c, stored, record.RequestHash)
+			}
+
+			// После того как обработчик начал выполняться, ключ нельзя бросать из-за отмены запроса:
+			// операция могла уже выполниться, поэтому аренда, сохранение и освобождение идут без отмены
+			detached := context.WithoutCancel(ctx)
+			stopRenewal := renewLease(detached, repo, logger, userID, key, lease)
+
+			response := c.Response()
+			recorder := newIdempotencyRecorder()
+			c.SetResponse(echo.NewResponse(recorder, c.Echo()))
+
+			// Паника обработчика не должна оставлять ключ занятым: освобождаем его и передаем панику дальше
+			defer func() {
+				if p := recover(); p != nil {
+					stopRenewal()
+					c.SetResponse(response)
+					release(detached, repo, logger, userID, key)
+					panic(p)
+				}
+			}()
+
+			// Ошибку обрабатываем здесь, чтобы записанный обработчиком ошибок ответ тоже попал в буфер
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			stopRenewal()
+			c.SetResponse(response)
+
+			if recorder.status >= http.StatusInternalServerError {
+				release(detached, repo, logger, userID, key)
+				return recorder.flush(response)
+			}
+
+			record.StatusCode = &recorder.status
+			record.ContentType = recorder.header.Get(echo.HeaderContentType)
+			record.Body = recorder.body.Bytes()
+			if completeErr := repo.Complete(detached, record); completeErr != nil {
+				logger.Error("не удалось сохранить ответ для ключа идемпотентности", "user_id", userID, "error", completeErr)
+				// Операция выполнена, а ответ не сохранен: держим ключ до конца его срока,
+				// чтобы после окончания аренды повтор не выполнил операцию второй раз
+				if extendErr := repo.Extend(detached, userID, key, record.ExpiresAt); extendErr != nil {
+					logger.Error("не удалось удержать ключ идемпотентности", "user_id", userID, "error", extendErr)
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+			}
+
+			return recorder.flush(response)
+		}
+	}
+}
+
+// renewLease продлевает аренду ключа каждые пол-аренды, пока не будет вызвана возвращенная функция.
+// Долгий запрос (например, ожидающий блокировку баланса) не теряет ключ, а аренда ключа
+// упавшего процесса истекает, потому что продлевать ее некому.
+func renewLease(
+	ctx context.Context,
+	repo domain.IdempotencyRepository,
+	logger *slog.Logger,
+	userID int,
+	key string,
+	lease time.Duration,
+) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := repo.Extend(ctx, userID, key, time.Now().Add(lease)); err != nil {
+					logger.Error("не удалось продлить аренду ключа идемпотентности", "user_id", userID, "error", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// release освобождает ключ идемпотентности, чтобы клиент мог повторить запрос.
+func release(ctx context.Context, repo domain.IdempotencyRepository, logger *slog.Logger, userID int, key string) {
+	if err := repo.Release(ctx, userID, key); err != nil {
+		logger.Error("не удалось освободить ключ идемпотентности", "user_id", userID, "error", err)
+	}
+}
+
+// replayIdempotent отвечает на повтор запроса с уже использованным ключом.
+func replayIdempotent(c echo.Context, stored *domain.IdempotencyRecord, hash string) error {
+	if stored.RequestHash != hash {
+		return echo.NewHTTPError(
+			http.StatusUnprocessableEntity,
+			"Idempotency-Key уже использован для запроса с другими параметрами",
+		)
+	}
+
+	if !stored.Completed() {
+		return echo.NewHTTPError(http.StatusConflict, "Запрос с этим Idempotency-Key еще выполняется")
+	}
+
+	c.Response().Header().Set(IdempotentReplayedHeader, "true")
+	if len(stored.Body) == 0 {
+		return c.NoContent(*stored.StatusCode)
+	}
+
+	return c.Blob(*stored.StatusCode, stored.ContentType, stored.Body)
+}
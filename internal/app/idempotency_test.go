@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+// memIdempotencyRepo хранилище ключей идемпотентности в памяти.
+type memIdempotencyRepo struct {
+	mu          sync.Mutex
+	records     map[string]*domain.IdempotencyRecord
+	completeErr error
+}
+
+func newMemIdempotencyRepo() *memIdempotencyRepo {
+	return &memIdempotencyRepo{records: make(map[string]*domain.IdempotencyRecord)}
+}
+
+func (r *memIdempotencyRepo) Reserve(
+	_ context.Context,
+	record *domain.IdempotencyRecord,
+) (*domain.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.records[record.Key]
+	if ok && (existing.Completed() || existing.LockedUntil.After(time.Now())) {
+		stored := *existing
+		return &stored, false, nil
+	}
+	stored := *record
+	r.records[record.Key] = &stored
+	return record, true, nil
+}
+
+func (r *memIdempotencyRepo) Extend(_ context.Context, _ int, key string, lockedUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.records[key]; ok && !record.Completed() {
+		record.LockedUntil = &lockedUntil
+	}
+	return nil
+}
+
+func (r *memIdempotencyRepo) Complete(_ context.Context, record *domain.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.completeErr != nil {
+		return r.completeErr
+	}
+	stored := *record
+	stored.LockedUntil = nil
+	r.records[record.Key] = &stored
+	return nil
+}
+
+func (r *memIdempotencyRepo) Release(_ context.Context, _ int, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, key)
+	return nil
+}
+
+func (r *memIdempotencyRepo) get(key string) *domain.IdempotencyRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.records[key]
+}
+
+// idempotentRoute создает echo с маршрутом POST /withdraw за IdempotencyMiddleware.
+// Обработчик выполняется delay и считает вызовы в calls.
+func idempotentRoute(repo domain.IdempotencyRepository, lease, delay time.Duration, calls *atomic.Int64) *echo.Echo {
+	e := echo.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	setUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user_id", 1)
+			return next(c)
+		}
+	}
+	e.POST("/withdraw", func(c echo.Context) error {
+		calls.Add(1)
+		time.Sleep(delay)
+		return c.String(http.StatusOK, "withdrawn")
+	}, setUser, IdempotencyMiddleware(repo, time.Hour, lease, logger))
+	return e
+}
+
+func postWithdraw(e *echo.Echo, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/withdraw", strings.NewReader(`{"sum":1}`))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddlewareReplaysStoredResponse(t *testing.T) {
+	var calls atomic.Int64
+	e := idempotentRoute(newMemIdempotencyRepo(), time.Minute, 0, &calls)
+
+	first := postWithdraw(e, "k1")
+	second := postWithdraw(e, "k1")
+
+	if first.Code != http.StatusOK || first.Body.String() != "withdrawn" {
+		t.Fatalf("first response = %d %q, want 200 %q", first.Code, first.Body.String(), "withdrawn")
+	}
+	if second.Code != http.StatusOK || second.Body.String() != "withdrawn" {
+		t.Errorf("replay = %d %q, want 200 %q", second.Code, second.Body.String(), "withdrawn")
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("replay has no %s header", IdempotentReplayedHeader)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", calls.Load())
+	}
+}
+
+func TestIdempotencyMiddlewareCompleteFailure(t *testing.T) {
+	var calls atomic.Int64
+	repo := newMemIdempotencyRepo()
+	repo.completeErr = errors.New("connection reset")
+	lease := 20 * time.Millisecond
+	e := idempotentRoute(repo, lease, 0, &calls)
+
+	rec := postWithdraw(e, "k1")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "withdrawn") {
+		t.Errorf("unsaved response was sent to the client: %q", rec.Body.String())
+	}
+
+	// Ключ удерживается дольше аренды: повтор не выполняет операцию второй раз
+	time.Sleep(2 * lease)
+	repo.completeErr = nil
+	if retry := postWithdraw(e, "k1"); retry.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", calls.Load())
+	}
+}
+
+func TestIdempotencyMiddlewareRenewsLease(t *testing.T) {
+	var calls atomic.Int64
+	repo := newMemIdempotencyRepo()
+	lease := 20 * time.Millisecond
+	e := idempotentRoute(repo, lease, 5*lease, &calls)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		postWithdraw(e, "k1")
+	}()
+
+	// Запрос выполняется дольше аренды: ключ остается за ним
+	time.Sleep(3 * lease)
+	if retry := postWithdraw(e, "k1"); retry.Code != http.StatusConflict {
+		t.Errorf("concurrent retry status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+	<-done
+
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", calls.Load())
+	}
+	if record := repo.get("k1"); record == nil || !record.Completed() {
+		t.Errorf("response was not stored: %+v", record)
+	}
+}
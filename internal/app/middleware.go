@@ -1,11 +1,17 @@
 package app
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/auth"
+	"gophermart/internal/domain"
 )
 
 // extractTokenFromHeader извлекает JWT токен из заголовка Authorization.
@@ -23,14 +29,9 @@ func extractTokenFromHeader(c echo.Context) (string, error) {
 	return parts[1], nil
 }
 
-// validateToken проверяет JWT токен и возвращает claims.
-func validateToken(tokenString string, secret string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Неверный метод подписи токена")
-		}
-		return []byte(secret), nil
-	})
+// validateToken проверяет JWT токен ключом из набора по kid и возвращает claims.
+func validateToken(tokenString string, keys *auth.KeySet) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
 
 	if err != nil {
 		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Неверный токен")
@@ -63,8 +64,25 @@ func extractUserData(claims jwt.MapClaims) (int, string, error) {
 	return int(userIDFloat), login, nil
 }
 
+// extractSessionID извлекает идентификатор сессии из claims.
+func extractSessionID(claims jwt.MapClaims) (int64, error) {
+	sessionIDFloat, ok := claims["sid"].(float64)
+	if !ok {
+		return 0, echo.NewHTTPError(http.StatusUnauthorized, "Отсутствует или неверный формат sid")
+	}
+
+	return int64(sessionIDFloat), nil
+}
+
 // JWTMiddleware создает middleware для проверки JWT токена.
-func JWTMiddleware(secret string) echo.MiddlewareFunc {
+// Токен принимается, только если его сессия не отозвана, а пользователь не заблокирован.
+// Роль пользователя в контексте запроса берется из базы, а не из claims токена.
+func JWTMiddleware(keys *auth.KeySet, sessions domain.SessionRepository) echo.MiddlewareFunc {
+	logger := slog.Default().With(
+		"package", "app",
+		"component", "JWTMiddleware",
+	)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Извлекаем токен из заголовка
@@ -74,7 +92,7 @@ func JWTMiddleware(secret string) echo.MiddlewareFunc {
 			}
 
 			// Проверяем токен и получаем claims
-			claims, err := validateToken(tokenString, secret)
+			claims, err := validateToken(tokenString, keys)
 			if err != nil {
 				return err
 			}
@@ -85,11 +103,79 @@ func JWTMiddleware(secret string) echo.MiddlewareFunc {
 				return err
 			}
 
+			// Проверяем, что сессия не отозвана
+			sessionID, err := extractSessionID(claims)
+			if err != nil {
+				return err
+			}
+
+			// Роль берется из базы вместе с проверкой сессии, чтобы снятие роли действовало до истечения токена
+			role, active, err := sessions.Authorize(sessionID, userID)
+			if err != nil {
+				logger.Error("не удалось проверить сессию", "user_id", userID, "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+			}
+			if !active {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Сессия завершена")
+			}
+
 			// Устанавливаем данные в контекст
 			c.Set("user_id", userID)
 			c.Set("login", login)
+			c.Set("session_id", sessionID)
+			c.Set("role", role)
 
 			return next(c)
 		}
 	}
 }
+
+// RequireRole создает middleware, пропускающий только пользователей с одной из указанных ролей.
+// Должен подключаться после JWTMiddleware.
+func RequireRole(roles ...domain.UserRole) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, ok := c.Get("role").(domain.UserRole)
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, "Недостаточно прав")
+			}
+
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(c)
+				}
+			}
+
+			return echo.NewHTTPError(http.StatusForbidden, "Недостаточно прав")
+		}
+	}
+}
+
+// MetricsMiddleware создает middleware, учитывающий каждый запрос по шаблону маршрута и коду ответа.
+func MetricsMiddleware(metrics domain.HTTPMetrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			// Ошибку в ответ превращает обработчик ошибок Echo уже после middleware,
+			// поэтому код ответа для нее определяем сами
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			metrics.ObserveHTTPRequest(c.Request().Method, route, status, time.Since(start))
+
+			return err
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -19,3 +20,27 @@ func MigrateDB(db *sql.DB, migrationsDir string) error {
 
 	return nil
 }
+
+// migrationsCheck возвращает проверку готовности: в базе применены все миграции из каталога.
+// Каталог читается один раз при запуске, проверка только запрашивает версию базы.
+func migrationsCheck(db *sql.DB, migrationsDir string) (func(ctx context.Context) error, error) {
+	migrations, collectErr := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if collectErr != nil {
+		return nil, fmt.Errorf("не удалось прочитать миграции: %w", collectErr)
+	}
+	last, lastErr := migrations.Last()
+	if lastErr != nil {
+		return nil, fmt.Errorf("не удалось определить последнюю миграцию: %w", lastErr)
+	}
+
+	return func(ctx context.Context) error {
+		version, err := goose.GetDBVersionContext(ctx, db)
+		if err != nil {
+			return fmt.Errorf("не удалось получить версию базы данных: %w", err)
+		}
+		if version < last.Version {
+			return fmt.Errorf("версия базы данных %d отстает от последней миграции %d", version, last.Version)
+		}
+		return nil
+	}, nil
+}
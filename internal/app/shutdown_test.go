@@ -0,0 +1,439 @@
+//go:build integration
+
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"gophermart/internal/accrualmock"
+	"gophermart/internal/domain"
+)
+
+const (
+	readyTimeout      = 30 * time.Second
+	readyPollInterval = 200 * time.Millisecond
+	requestTimeout    = 5 * time.Second
+	readHeaderTimeout = 5 * time.Second
+	exitMargin        = 5 * time.Second
+	orderNumberDigits = 12
+	accrualReward     = 100
+	accrualDelay      = 500 * time.Millisecond
+	accrualSlowDelay  = 1 * time.Second
+	accrualSlowRate   = 0.3
+
+	loadUsers   = 5
+	loadClients = 20
+	loadWarmup  = 3 * time.Second
+)
+
+// shutdownPhases таймауты этапов остановки, передаваемые gophermart.
+type shutdownPhases struct {
+	ReadinessDelay time.Duration
+	HTTP           time.Duration
+	Worker         time.Duration
+	DB             time.Duration
+}
+
+// total возвращает максимальное время остановки.
+func (p shutdownPhases) total() time.Duration {
+	return p.ReadinessDelay + p.HTTP + p.Worker + p.DB
+}
+
+// loadStats результаты запросов нагрузки.
+type loadStats struct {
+	requests       atomic.Int64
+	refused        atomic.Int64
+	serverErrors   atomic.Int64
+	earlyFailures  atomic.Int64
+	mu             sync.Mutex
+	failureSamples []string
+}
+
+// fail сохраняет описание ошибки для итогового отчета.
+func (s *loadStats) fail(format string, args ...any) {
+	const maxSamples = 10
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failureSamples) < maxSamples {
+		s.failureSamples = append(s.failureSamples, fmt.Sprintf(format, args...))
+	}
+}
+
+// lockedBuffer буфер, в который одновременно пишут stdout и stderr процесса.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// buildGophermart собирает бинарник gophermart во временный каталог теста.
+func buildGophermart(t *testing.T) string {
+	t.Helper()
+
+	binaryPath := filepath.Join(t.TempDir(), "gophermart")
+	//nolint:gosec // G204: аргументы команды заданы в тесте
+	build := exec.Command("go", "build", "-o", binaryPath, "gophermart/cmd/gophermart")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build gophermart: %v\n%s", err, output)
+	}
+	return binaryPath
+}
+
+// TestGracefulShutdown проверяет корректную остановку gophermart под нагрузкой.
+// Запускает бинарник gophermart с имитацией системы начислений, нагружает API загрузкой заказов
+// и чтением баланса, отправляет SIGTERM и проверяет, что:
+//   - после сигнала /readyz отвечает 503, пока слушатель еще открыт;
+//   - ни один запрос не получил ответ 5xx;
+//   - процесс завершился с кодом 0 в пределах суммы таймаутов этапов остановки;
+//   - в журнале нет обращений к закрытому пулу соединений и паник.
+//
+// Требует запущенный PostgreSQL; без DATABASE_URI тест пропускается.
+func TestGracefulShutdown(t *testing.T) {
+	databaseURI := os.Getenv("DATABASE_URI")
+	if databaseURI == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	phases := shutdownPhases{
+		ReadinessDelay: 1 * time.Second,
+		HTTP:           5 * time.Second,
+		Worker:         5 * time.Second,
+		DB:             3 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	binaryPath := buildGophermart(t)
+
+	// Имитация системы начислений: часть ответов медленные, чтобы остановка застала воркеры посреди заказа
+	accrualAddress, stopAccrual, err := startAccrualMock(logger)
+	if err != nil {
+		t.Fatalf("failed to start accrual mock: %v", err)
+	}
+	defer stopAccrual()
+
+	address, err := freeAddress()
+	if err != nil {
+		t.Fatalf("failed to pick port: %v", err)
+	}
+	baseURL := "http://" + address
+
+	var output lockedBuffer
+	//nolint:gosec // G204: бинарник собран самим тестом
+	cmd := exec.Command(binaryPath)
+	cmd.Env = append(os.Environ(),
+		"RUN_ADDRESS="+address,
+		"DATABASE_URI="+databaseURI,
+		"ACCRUAL_SYSTEM_ADDRESS="+accrualAddress,
+		"JWT_SECRET=shutdowntest-secret",
+		"ACCRUAL_POLL_INTERVAL=200ms",
+		"SHUTDOWN_READINESS_DELAY="+phases.ReadinessDelay.String(),
+		"SHUTDOWN_HTTP_TIMEOUT="+phases.HTTP.String(),
+		"SHUTDOWN_WORKER_TIMEOUT="+phases.Worker.String(),
+		"SHUTDOWN_DB_TIMEOUT="+phases.DB.String(),
+	)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if startErr := cmd.Start(); startErr != nil {
+		t.Fatalf("failed to start gophermart: %v", startErr)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	client := &http.Client{Timeout: requestTimeout}
+	if readyErr := waitReady(client, baseURL, exited); readyErr != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("gophermart did not become ready: %v\n%s", readyErr, output.String())
+	}
+
+	tokens, err := registerUsers(client, baseURL, loadUsers)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("failed to register users: %v", err)
+	}
+
+	// Нагрузка продолжается и после сигнала, пока процесс не завершится
+	var (
+		stats     loadStats
+		signalled atomic.Bool
+		wg        sync.WaitGroup
+	)
+	loadCtx, stopLoad := context.WithCancel(context.Background())
+	defer stopLoad()
+	for i := range loadClients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			generateLoad(loadCtx, client, baseURL, tokens[i%len(tokens)], &signalled, &stats)
+		}(i)
+	}
+
+	time.Sleep(loadWarmup)
+
+	t.Logf("sending SIGTERM after %d requests", stats.requests.Load())
+	signalled.Store(true)
+	signalledAt := time.Now()
+	if signalErr := cmd.Process.Signal(syscall.SIGTERM); signalErr != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("failed to send SIGTERM: %v", signalErr)
+	}
+
+	// Пока длится пауза после снятия готовности, слушатель открыт, а /readyz должен отвечать 503
+	notReady := phases.ReadinessDelay <= 0 || checkNotReady(client, baseURL)
+
+	var exitErr error
+	exitedInTime := true
+	select {
+	case exitErr = <-exited:
+	case <-time.After(phases.total() + exitMargin):
+		exitedInTime = false
+		_ = cmd.Process.Kill()
+		exitErr = <-exited
+	}
+	shutdownDuration := time.Since(signalledAt)
+	stopLoad()
+	wg.Wait()
+
+	if !notReady {
+		t.Error("readiness did not turn false after SIGTERM")
+	}
+	if n := stats.serverErrors.Load(); n != 0 {
+		t.Errorf("got %d 5xx responses of %d requests", n, stats.requests.Load())
+	}
+	if n := stats.earlyFailures.Load(); n != 0 {
+		t.Errorf("got %d transport errors before SIGTERM", n)
+	}
+	if !exitedInTime {
+		t.Errorf("process did not exit within shutdown budget %s", phases.total()+exitMargin)
+	}
+	if exitErr != nil {
+		t.Errorf("process exited with error: %v", exitErr)
+	}
+	logOutput := output.String()
+	for _, marker := range []string{"database is closed", "panic:"} {
+		if strings.Contains(logOutput, marker) {
+			t.Errorf("log contains %q", marker)
+		}
+	}
+	t.Logf("shutdown took %s, %d requests refused after SIGTERM", shutdownDuration, stats.refused.Load())
+
+	if t.Failed() {
+		stats.mu.Lock()
+		for _, sample := range stats.failureSamples {
+			t.Logf("failure sample: %s", sample)
+		}
+		stats.mu.Unlock()
+		t.Logf("gophermart output:\n%s", logOutput)
+	}
+}
+
+// startAccrualMock запускает имитацию системы начислений на свободном порту.
+func startAccrualMock(logger *slog.Logger) (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	server := &http.Server{
+		Handler: accrualmock.NewServer(accrualmock.Config{
+			ProcessingDelay: accrualDelay,
+			SlowRate:        accrualSlowRate,
+			SlowDelay:       accrualSlowDelay,
+			AutoReward:      domain.Money(accrualReward),
+		}, logger).Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return "http://" + listener.Addr().String(), func() { _ = server.Close() }, nil
+}
+
+// freeAddress возвращает адрес со свободным портом для gophermart.
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// waitReady ждет, пока /readyz ответит 200, или завершения процесса.
+func waitReady(client *http.Client, baseURL string, exited <-chan error) error {
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("process exited: %w", err)
+		default:
+		}
+
+		resp, err := client.Get(baseURL + "/readyz")
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(readyPollInterval)
+	}
+	return errors.New("timeout waiting for /readyz")
+}
+
+// checkNotReady проверяет, что после сигнала /readyz отвечает 503.
+func checkNotReady(client *http.Client, baseURL string) bool {
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(baseURL + "/readyz")
+		if err != nil {
+			// Слушатель уже закрыт, так и не показав 503
+			return false
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return true
+		}
+		time.Sleep(readyPollInterval / 4)
+	}
+	return false
+}
+
+// registerUsers регистрирует пользователей и возвращает их access-токены.
+func registerUsers(client *http.Client, baseURL string, count int) ([]string, error) {
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	tokens := make([]string, 0, count)
+	for i := range count {
+		body, _ := json.Marshal(domain.RegisterRequest{
+			Login:    fmt.Sprintf("shutdowntest-%s-%d", suffix, i),
+			Password: "Shutdown-test-1",
+		})
+		resp, err := client.Post(baseURL+"/api/user/register", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		var token domain.AuthToken
+		decodeErr := json.NewDecoder(resp.Body).Decode(&token)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || decodeErr != nil {
+			return nil, fmt.Errorf("register returned %d: %w", resp.StatusCode, decodeErr)
+		}
+		tokens = append(tokens, token.Token)
+	}
+	return tokens, nil
+}
+
+// generateLoad загружает заказы и читает заказы и баланс пользователя до отмены ctx.
+func generateLoad(
+	ctx context.Context,
+	client *http.Client,
+	baseURL, token string,
+	signalled *atomic.Bool,
+	stats *loadStats,
+) {
+	for ctx.Err() == nil {
+		requests := []struct {
+			method, path, contentType, body string
+		}{
+			{http.MethodPost, "/api/user/orders", "text/plain", luhnNumber()},
+			{http.MethodGet, "/api/user/orders", "", ""},
+			{http.MethodGet, "/api/user/balance", "", ""},
+		}
+		for _, r := range requests {
+			req, err := http.NewRequestWithContext(ctx, r.method, baseURL+r.path, strings.NewReader(r.body))
+			if err != nil {
+				return
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			if r.contentType != "" {
+				req.Header.Set("Content-Type", r.contentType)
+			}
+
+			stats.requests.Add(1)
+			resp, err := client.Do(req)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// После сигнала отказ в соединении ожидаем: слушатель закрыт
+				if signalled.Load() {
+					stats.refused.Add(1)
+				} else {
+					stats.earlyFailures.Add(1)
+					stats.fail("%s %s: %v", r.method, r.path, err)
+				}
+				continue
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			if resp.StatusCode >= http.StatusInternalServerError {
+				stats.serverErrors.Add(1)
+				stats.fail("%s %s: %d", r.method, r.path, resp.StatusCode)
+			}
+		}
+	}
+}
+
+// luhnNumber возвращает случайный номер заказа, проходящий проверку по алгоритму Луна.
+func luhnNumber() string {
+	digits := make([]int, orderNumberDigits)
+	for i := range orderNumberDigits - 1 {
+		//nolint:gosec // G404: номера заказов для нагрузки не требуют криптостойкости
+		digits[i] = rand.IntN(10)
+	}
+
+	// Контрольная цифра дополняет сумму до кратной 10; удваиваются цифры на четных позициях справа
+	sum := 0
+	for i := orderNumberDigits - 2; i >= 0; i-- {
+		d := digits[i]
+		if (orderNumberDigits-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	digits[orderNumberDigits-1] = (10 - sum%10) % 10
+
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteByte(byte('0' + d))
+	}
+	return b.String()
+}
@@ -0,0 +1,268 @@
+// Package auth содержит набор ключей для подписи и проверки JWT токенов.
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// pemExtension расширение файлов ключей в каталоге.
+	pemExtension = ".pem"
+	// secretKIDPrefix префикс идентификатора ключа общего секрета HS256.
+	secretKIDPrefix = "hs256-"
+	// secretKIDLength количество шестнадцатеричных символов HMAC в идентификаторе ключа.
+	secretKIDLength = 16
+)
+
+var (
+	// ErrNoSigningKey ошибка в наборе нет ключа, которым можно подписывать токены.
+	ErrNoSigningKey = errors.New("не задан ключ для подписи JWT токенов")
+	// ErrUnknownKey ошибка токен подписан неизвестным ключом.
+	ErrUnknownKey = errors.New("неизвестный ключ подписи токена")
+	// ErrUnsupportedKey ошибка тип ключа не поддерживается.
+	ErrUnsupportedKey = errors.New("неподдерживаемый тип ключа")
+)
+
+// Signer подписывает claims текущим ключом набора.
+type Signer interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// Key ключ подписи с идентификатором kid.
+// Ключ без закрытой части только проверяет подписи ранее выданных токенов.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	signingKey any
+	verifyKey  any
+}
+
+// CanSign сообщает, можно ли подписывать токены этим ключом.
+func (k *Key) CanSign() bool {
+	return k.signingKey != nil
+}
+
+// KeySet набор ключей подписи JWT.
+// Новые токены подписываются самым новым ключом с закрытой частью, а токены,
+// подписанные остальными ключами набора, принимаются до истечения их срока действия.
+type KeySet struct {
+	keys    map[string]*Key
+	ordered []*Key // от старых к новым
+	current *Key
+	// legacy ключ для токенов без заголовка kid, выданных до появления набора ключей
+	legacy *Key
+}
+
+// NewKeySet создает набор ключей из общего секрета HS256 и PEM-файлов каталога.
+// Секрет считается самым старым ключом, его kid задается secretKID или, если он пуст, выводится
+// из секрета, поэтому одинаков у всех экземпляров и после перезапуска.
+// Файлы упорядочиваются по имени, поэтому их
+// удобно называть по дате выпуска (например, 2024-06-01.pem); имя файла без расширения
+// становится kid. Файл с открытым ключом (PUBLIC KEY) добавляет ключ только для проверки.
+func NewKeySet(secret, secretKID, keysDir string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key)}
+
+	if secret != "" {
+		if secretKID == "" {
+			secretKID = deriveSecretKID(secret)
+		}
+		key := &Key{
+			ID:         secretKID,
+			Method:     jwt.SigningMethodHS256,
+			signingKey: []byte(secret),
+			verifyKey:  []byte(secret),
+		}
+		ks.add(key)
+		ks.legacy = key
+	}
+
+	if keysDir != "" {
+		if err := ks.loadDir(keysDir); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(ks.ordered) - 1; i >= 0; i-- {
+		if ks.ordered[i].CanSign() {
+			ks.current = ks.ordered[i]
+			break
+		}
+	}
+	if ks.current == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return ks, nil
+}
+
+// loadDir загружает PEM-файлы каталога в порядке имен.
+func (ks *KeySet) loadDir(dir string) error {
+	paths, globErr := filepath.Glob(filepath.Join(dir, "*"+pemExtension))
+	if globErr != nil {
+		return fmt.Errorf("failed to list keys directory: %w", globErr)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return fmt.Errorf("failed to read key file %s: %w", path, readErr)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), pemExtension)
+		key, parseErr := parsePEMKey(kid, data)
+		if parseErr != nil {
+			return fmt.Errorf("failed to parse key file %s: %w", path, parseErr)
+		}
+		ks.add(key)
+	}
+
+	return nil
+}
+
+// add добавляет ключ в набор; ключ с тем же kid заменяет прежний.
+func (ks *KeySet) add(key *Key) {
+	if _, exists := ks.keys[key.ID]; exists {
+		for i, k := range ks.ordered {
+			if k.ID == key.ID {
+				ks.ordered = append(ks.ordered[:i], ks.ordered[i+1:]...)
+				break
+			}
+		}
+	}
+	ks.keys[key.ID] = key
+	ks.ordered = append(ks.ordered, key)
+}
+
+// Current возвращает ключ, которым подписываются новые токены.
+func (ks *KeySet) Current() *Key {
+	return ks.current
+}
+
+// Sign подписывает claims текущим ключом и добавляет его kid в заголовок токена.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.current.Method, claims)
+	token.Header["kid"] = ks.current.ID
+
+	signed, err := token.SignedString(ks.current.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Keyfunc выбирает ключ проверки по kid из заголовка токена для jwt.Parse.
+// Алгоритм токена должен совпадать с алгоритмом ключа.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = ks.keys[kid]
+	} else {
+		key = ks.legacy
+	}
+
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: алгоритм %s не совпадает с ключом", ErrUnknownKey, token.Method.Alg())
+	}
+
+	return key.verifyKey, nil
+}
+
+// Methods возвращает алгоритмы ключей набора для jwt.WithValidMethods.
+func (ks *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	methods := make([]string, 0, len(ks.ordered))
+	for _, key := range ks.ordered {
+		alg := key.Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// deriveSecretKID выводит идентификатор ключа из секрета через HMAC-SHA256 с секретом в качестве ключа.
+// В отличие от простого хеша секрета, проверить по такому kid догадку о секрете не проще,
+// чем по подписи самого токена.
+func deriveSecretKID(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("kid"))
+	return secretKIDPrefix + hex.EncodeToString(mac.Sum(nil))[:secretKIDLength]
+}
+
+// parsePEMKey разбирает закрытый (PKCS#1, PKCS#8) или открытый (PKIX) ключ RSA или Ed25519.
+func parsePEMKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM block not found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newPrivateKey(kid, private)
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newPrivateKey(kid, private)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newPublicKey(kid, public)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+}
+
+// newPrivateKey создает ключ для подписи и проверки.
+func newPrivateKey(kid string, private any) (*Key, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, private)
+	}
+
+	key, err := newPublicKey(kid, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.signingKey = private
+	return key, nil
+}
+
+// newPublicKey создает ключ только для проверки.
+func newPublicKey(kid string, public any) (*Key, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, verifyKey: pub}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSecretKIDIsStable(t *testing.T) {
+	first, err := NewKeySet("secret", "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	second, err := NewKeySet("secret", "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	kid := first.Current().ID
+	if kid != second.Current().ID {
+		t.Errorf("kid differs between key sets: %q and %q", kid, second.Current().ID)
+	}
+	if !strings.HasPrefix(kid, secretKIDPrefix) || len(kid) != len(secretKIDPrefix)+secretKIDLength {
+		t.Errorf("kid = %q, want %s followed by %d hex characters", kid, secretKIDPrefix, secretKIDLength)
+	}
+
+	// Токен, подписанный одним экземпляром, принимается другим (или тем же после перезапуска)
+	signed, err := first.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err = jwt.Parse(signed, second.Keyfunc, jwt.WithValidMethods(second.Methods())); err != nil {
+		t.Errorf("token from another key set rejected: %v", err)
+	}
+
+	other, err := NewKeySet("other-secret", "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	if other.Current().ID == kid {
+		t.Errorf("different secrets have the same kid %q", kid)
+	}
+}
+
+func TestSecretKIDFromConfig(t *testing.T) {
+	ks, err := NewKeySet("secret", "hs256-main", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	if ks.Current().ID != "hs256-main" {
+		t.Errorf("kid = %q, want %q", ks.Current().ID, "hs256-main")
+	}
+}
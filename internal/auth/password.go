@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Алгоритмы хеширования паролей.
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2idParts   = 6
+	argon2idTime    = 1
+	argon2idMemory  = 64 * 1024 // КиБ
+	argon2idThreads = 4
+	argon2idKeyLen  = 32
+	argon2idSaltLen = 16
+)
+
+var (
+	// ErrUnknownHashAlgorithm ошибка неизвестный алгоритм хеширования паролей.
+	ErrUnknownHashAlgorithm = errors.New("неизвестный алгоритм хеширования паролей")
+	// ErrMalformedHash ошибка хеш пароля имеет неизвестный формат.
+	ErrMalformedHash = errors.New("неверный формат хеша пароля")
+)
+
+// argon2idParams параметры argon2id, сохраняемые вместе с хешем.
+type argon2idParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// PasswordHasher реализует domain.PasswordHasher для bcrypt и argon2id.
+// Новые хеши вычисляются выбранным алгоритмом, а проверяются хеши обоих форматов,
+// поэтому смена алгоритма не требует сброса паролей: хеш обновляется при следующем входе.
+type PasswordHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon      argon2idParams
+}
+
+// NewPasswordHasher создает новый экземпляр PasswordHasher для указанного алгоритма.
+func NewPasswordHasher(algorithm string) (*PasswordHasher, error) {
+	switch algorithm {
+	case "", PasswordHashBcrypt:
+		algorithm = PasswordHashBcrypt
+	case PasswordHashArgon2id:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, algorithm)
+	}
+
+	return &PasswordHasher{
+		algorithm:  algorithm,
+		bcryptCost: bcrypt.DefaultCost,
+		argon: argon2idParams{
+			time:    argon2idTime,
+			memory:  argon2idMemory,
+			threads: argon2idThreads,
+		},
+	}, nil
+}
+
+// Hash вычисляет хеш пароля выбранным алгоритмом.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == PasswordHashArgon2id {
+		return h.hashArgon2id(password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Verify проверяет пароль по хешу любого поддерживаемого формата.
+func (h *PasswordHasher) Verify(hash, password string) (bool, error) {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		return verifyArgon2id(hash, password)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+}
+
+// NeedsRehash сообщает, что хеш вычислен другим алгоритмом или с другими параметрами.
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		if h.algorithm != PasswordHashArgon2id {
+			return true
+		}
+		params, _, _, err := parseArgon2id(hash)
+		return err != nil || params != h.argon
+	}
+
+	if h.algorithm != PasswordHashBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.bcryptCost
+}
+
+// hashArgon2id вычисляет хеш argon2id в формате PHC: $argon2id$v=19$m=...,t=...,p=...$соль$хеш.
+func (h *PasswordHasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon.time, h.argon.memory, h.argon.threads, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.argon.memory, h.argon.time, h.argon.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyArgon2id проверяет пароль по хешу argon2id с параметрами из самого хеша.
+func verifyArgon2id(hash, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	//nolint:gosec // G115: длина ключа ограничена при разборе хеша
+	candidate := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+// parseArgon2id разбирает хеш argon2id в формате PHC.
+func parseArgon2id(hash string) (argon2idParams, []byte, []byte, error) {
+	var params argon2idParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != argon2idParts {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, saltErr := base64.RawStdEncoding.DecodeString(parts[4])
+	key, keyErr := base64.RawStdEncoding.DecodeString(parts[5])
+	if saltErr != nil || keyErr != nil || len(key) == 0 || len(key) > argon2idKeyLen*2 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrAccrualOrderNotFound ошибка заказ не зарегистрирован в системе начислений (ответ 204).
+	ErrAccrualOrderNotFound = errors.New("заказ не найден в системе начислений")
+	// ErrAccrualCircuitOpen ошибка запрос не выполнен, так как система начислений признана недоступной.
+	ErrAccrualCircuitOpen = errors.New("система начислений недоступна, запросы временно приостановлены")
+	// ErrAccrualMalformedResponse ошибка ответ системы начислений не удалось разобрать.
+	ErrAccrualMalformedResponse = errors.New("неверный формат ответа системы начислений")
+)
+
+// AccrualRateLimitError ошибка превышения лимита запросов к системе начислений (ответ 429).
+type AccrualRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccrualRateLimitError) Error() string {
+	return fmt.Sprintf("превышен лимит запросов, повторить через %v", e.RetryAfter)
+}
+
+// AccrualClient определяет интерфейс источника информации о начислениях.
+// Реализации возвращают ErrAccrualOrderNotFound, *AccrualRateLimitError, ErrAccrualCircuitOpen
+// и ошибки, оборачивающие ErrAccrualMalformedResponse, чтобы воркер одинаково обрабатывал любой источник.
+type AccrualClient interface {
+	// GetOrderAccrual получает информацию о начислении баллов за заказ.
+	GetOrderAccrual(ctx context.Context, orderNumber string) (*OrderAccrual, error)
+}
+
+// CircuitState состояние автоматического выключателя клиента системы начислений.
+type CircuitState string
+
+const (
+	// CircuitClosed запросы выполняются в обычном режиме.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen запросы не выполняются до истечения таймаута.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen выполняется пробный запрос для проверки восстановления системы.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStatus представляет текущее состояние автоматического выключателя.
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// AccrualStatusProvider предоставляет состояние клиента системы начислений.
+type AccrualStatusProvider interface {
+	CircuitStatus() CircuitStatus
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// Adjustment представляет ручную корректировку баланса или возврат списания.
+// Сумма всегда положительна, знак операции задает направление со стороны счета пользователя.
+type Adjustment struct {
+	ID           int                 `json:"id"                      db:"id"`
+	UserID       int                 `json:"user_id"                 db:"user_id"`
+	OperatorID   int                 `json:"operator_id"             db:"operator_id"`
+	Kind         LedgerReferenceType `json:"kind"                    db:"kind"`
+	Direction    LedgerDirection     `json:"direction"               db:"direction"`
+	Amount       Money               `json:"amount"                  db:"amount_kop"`
+	WithdrawalID *int                `json:"withdrawal_id,omitempty" db:"withdrawal_id"`
+	Reason       string              `json:"reason"                  db:"reason"`
+	CreatedAt    time.Time           `json:"created_at"              db:"created_at"`
+}
+
+// AdjustmentRequest представляет запрос на ручную корректировку баланса.
+type AdjustmentRequest struct {
+	Amount Money  `json:"amount" validate:"required"` // положительная сумма зачисляется, отрицательная списывается
+	Reason string `json:"reason" validate:"required"`
+}
+
+// WithdrawalReversalRequest представляет запрос на возврат списания.
+type WithdrawalReversalRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// AuditActorType тип участника, выполнившего действие.
+type AuditActorType string
+
+const (
+	// AuditActorUser действие выполнено аутентифицированным пользователем.
+	AuditActorUser AuditActorType = "user"
+	// AuditActorSystem действие выполнено фоновым процессом.
+	AuditActorSystem AuditActorType = "system"
+	// AuditActorAnonymous действие выполнено неаутентифицированным клиентом.
+	AuditActorAnonymous AuditActorType = "anonymous"
+)
+
+// AuditAction действие, записываемое в журнал аудита.
+type AuditAction string
+
+// События безопасности.
+const (
+	AuditUserRegistered      AuditAction = "user.registered"
+	AuditUserLogin           AuditAction = "user.login"
+	AuditUserLoginFailed     AuditAction = "user.login_failed"
+	AuditUserLogout          AuditAction = "user.logout"
+	AuditUserLogoutAll       AuditAction = "user.logout_all"
+	AuditUserPasswordChanged AuditAction = "user.password_changed"
+	AuditRefreshTokenReused  AuditAction = "user.refresh_token_reused"
+	AuditUserBlocked         AuditAction = "admin.user_blocked"
+	AuditUserUnblocked       AuditAction = "admin.user_unblocked"
+	AuditRoleGranted         AuditAction = "admin.role_granted"
+	AuditRoleRevoked         AuditAction = "admin.role_revoked"
+)
+
+// События заказов и операций с баллами.
+const (
+	AuditOrderUploaded      AuditAction = "order.uploaded"
+	AuditAccrualProcessed   AuditAction = "accrual.processed"
+	AuditAccrualInvalid     AuditAction = "accrual.invalid"
+	AuditBalanceWithdrawn   AuditAction = "balance.withdrawn"
+	AuditBalanceAdjusted    AuditAction = "admin.balance_adjusted"
+	AuditWithdrawalReversed AuditAction = "admin.withdrawal_reversed"
+)
+
+// Типы объектов, над которыми выполняется действие.
+const (
+	AuditTargetUser       = "user"
+	AuditTargetOrder      = "order"
+	AuditTargetWithdrawal = "withdrawal"
+	AuditTargetAdjustment = "adjustment"
+)
+
+// RequestMeta сведения о клиенте, выполнившем запрос.
+type RequestMeta struct {
+	IP        string
+	UserAgent string
+}
+
+// AuditPayload произвольные данные события в формате JSON.
+type AuditPayload []byte
+
+// MarshalJSON реализует интерфейс json.Marshaler для AuditPayload.
+func (p AuditPayload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+// Value реализует интерфейс driver.Valuer для AuditPayload.
+func (p AuditPayload) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return string(p), nil
+}
+
+// Scan реализует интерфейс sql.Scanner для AuditPayload.
+func (p *AuditPayload) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+	case []byte:
+		*p = append(AuditPayload(nil), v...)
+	case string:
+		*p = AuditPayload(v)
+	default:
+		return fmt.Errorf("unable to scan %T into AuditPayload", value)
+	}
+	return nil
+}
+
+// AuditEvent представляет запись журнала аудита.
+// Идентификатор, время и хеши назначает база данных при вставке.
+type AuditEvent struct {
+	ID         int64          `json:"id"                    db:"id"`
+	CreatedAt  time.Time      `json:"created_at"            db:"created_at"`
+	ActorType  AuditActorType `json:"actor_type"            db:"actor_type"`
+	ActorID    *int           `json:"actor_id,omitempty"    db:"actor_id"`
+	Action     AuditAction    `json:"action"                db:"action"`
+	TargetType string         `json:"target_type,omitempty" db:"target_type"`
+	TargetID   string         `json:"target_id,omitempty"   db:"target_id"`
+	IP         string         `json:"ip,omitempty"          db:"ip"`
+	UserAgent  string         `json:"user_agent,omitempty"  db:"user_agent"`
+	Payload    AuditPayload   `json:"payload,omitempty"     db:"payload"`
+	PrevHash   string         `json:"prev_hash"             db:"prev_hash"`
+	Hash       string         `json:"hash"                  db:"hash"`
+}
+
+// AuditFilter условия выборки событий аудита. События возвращаются от новых к старым.
+type AuditFilter struct {
+	ActorID    *int
+	Action     AuditAction
+	TargetType string
+	TargetID   string
+	IP         string
+	Since      *time.Time
+	Until      *time.Time
+	BeforeID   int64 // курсор: только события с меньшим идентификатором (0 - с последнего события)
+	Limit      int
+}
+
+// AuditPage страница событий аудита.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"` // пусто, если событий больше нет
+}
+
+// AuditChainViolation нарушение цепочки хешей журнала аудита.
+type AuditChainViolation struct {
+	EventID int64  `json:"event_id" db:"id"`
+	Reason  string `json:"reason"   db:"reason"`
+}
+
+// AuditRecorder записывает события аудита.
+type AuditRecorder interface {
+	// Record сохраняет событие. Ошибка записи не должна прерывать операцию, вызвавшую событие.
+	Record(event AuditEvent)
+}
+
+// AuditRepository определяет интерфейс для доступа к журналу аудита.
+type AuditRepository interface {
+	// Append добавляет событие и заполняет назначенные базой поля.
+	Append(event *AuditEvent) error
+	// Find возвращает события по фильтру, от новых к старым.
+	Find(filter AuditFilter) ([]AuditEvent, error)
+	// VerifyChain пересчитывает цепочку хешей и возвращает до limit нарушений.
+	VerifyChain(limit int) ([]AuditChainViolation, error)
+}
+
+// AuditService определяет интерфейс для просмотра и проверки журнала аудита.
+type AuditService interface {
+	Find(filter AuditFilter) (*AuditPage, error)
+	VerifyChain() ([]AuditChainViolation, error)
+}
@@ -1,37 +1,75 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Balance представляет баланс пользователя.
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   Money `json:"current"   db:"current"`
+	Withdrawn Money `json:"withdrawn" db:"withdrawn"`
 }
 
 // Withdrawal представляет списание средств.
 type Withdrawal struct {
-	Order       string    `json:"order"        db:"order_number"`
-	Sum         float64   `json:"sum"          db:"-"`
-	AmountKop   int64     `json:"-"            db:"amount_kop"`
-	ProcessedAt time.Time `json:"processed_at" db:"processed_at"`
+	ID          int        `json:"-"                     db:"id"`
+	Order       string     `json:"order"                 db:"order_number"`
+	Sum         Money      `json:"sum"                   db:"amount_kop"`
+	ProcessedAt time.Time  `json:"processed_at"          db:"processed_at"`
+	ReversedAt  *time.Time `json:"reversed_at,omitempty" db:"reversed_at"` // время возврата, если списание отменено
+}
+
+// WithdrawalRecord представляет списание вместе с идентификатором, по которому его можно вернуть.
+type WithdrawalRecord struct {
+	ID int `json:"id"`
+	Withdrawal
+}
+
+// BalanceOperation представляет одну операцию в истории баланса пользователя.
+type BalanceOperation struct {
+	Type      LedgerReferenceType `json:"type"             db:"reference_type"`
+	Amount    Money               `json:"amount"           db:"amount_kop"` // положительна для зачислений
+	Order     string              `json:"order,omitempty"  db:"order_number"`
+	Reason    string              `json:"reason,omitempty" db:"reason"`
+	CreatedAt time.Time           `json:"created_at"       db:"created_at"`
 }
 
 // WithdrawalRequest представляет запрос на списание средств.
 type WithdrawalRequest struct {
-	Order string  `json:"order" validate:"required"`
-	Sum   float64 `json:"sum"   validate:"required,gt=0"`
+	Order string `json:"order" validate:"required"`
+	Sum   Money  `json:"sum"   validate:"required,gt=0"`
+}
+
+// BalanceTx определяет операции с балансом, выполняемые внутри транзакции.
+type BalanceTx interface {
+	GetBalance(ctx context.Context, userID int) (*Balance, error)
+	CreateWithdrawal(ctx context.Context, userID int, withdrawal *Withdrawal) error
+	// GetWithdrawal возвращает списание пользователя или ErrWithdrawalNotFound.
+	GetWithdrawal(ctx context.Context, userID, withdrawalID int) (*Withdrawal, error)
+	// CreateAdjustment создает корректировку баланса вместе с проводкой в журнале.
+	CreateAdjustment(ctx context.Context, adjustment *Adjustment) error
 }
 
 // BalanceRepository определяет интерфейс для работы с балансом.
 type BalanceRepository interface {
-	GetBalance(userID int) (*Balance, error)
-	CreateWithdrawal(userID int, withdrawal *Withdrawal) error
-	GetWithdrawals(userID int) ([]Withdrawal, error)
+	GetBalance(ctx context.Context, userID int) (*Balance, error)
+	// GetWithdrawals возвращает списания пользователя по фильтру.
+	GetWithdrawals(ctx context.Context, userID int, filter WithdrawalFilter) ([]Withdrawal, error)
+	// GetAdjustments возвращает корректировки баланса пользователя, начиная с последней.
+	GetAdjustments(ctx context.Context, userID int) ([]Adjustment, error)
+	// GetHistory возвращает все операции по балансу пользователя, начиная с последней.
+	GetHistory(ctx context.Context, userID int) ([]BalanceOperation, error)
+	// WithBalanceLock выполняет fn в одной транзакции, удерживая блокировку баланса пользователя.
+	// Параллельные вызовы для одного пользователя выполняются строго последовательно.
+	WithBalanceLock(ctx context.Context, userID int, fn func(tx BalanceTx) error) error
 }
 
 // BalanceService определяет интерфейс для бизнес-логики работы с балансом.
 type BalanceService interface {
-	GetBalance(userID int) (*Balance, error)
-	Withdraw(userID int, req *WithdrawalRequest) error
-	GetWithdrawals(userID int) ([]Withdrawal, error)
+	GetBalance(ctx context.Context, userID int) (*Balance, error)
+	Withdraw(ctx context.Context, userID int, req *WithdrawalRequest, meta RequestMeta) error
+	// GetWithdrawals возвращает страницу списаний пользователя и курсор следующей страницы.
+	GetWithdrawals(ctx context.Context, userID int, filter WithdrawalFilter) (*WithdrawalPage, error)
+	GetHistory(ctx context.Context, userID int) ([]BalanceOperation, error)
 }
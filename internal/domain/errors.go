@@ -5,4 +5,12 @@ import "errors"
 var (
 	// ErrInvalidOrderNumber ошибка неверный номер заказа.
 	ErrInvalidOrderNumber = errors.New("неверный номер заказа")
+	// ErrInsufficientFunds ошибка недостаточно средств на балансе.
+	ErrInsufficientFunds = errors.New("недостаточно средств")
+	// ErrWithdrawalNotFound ошибка списание не найдено.
+	ErrWithdrawalNotFound = errors.New("списание не найдено")
+	// ErrWithdrawalReversed ошибка списание уже было возвращено.
+	ErrWithdrawalReversed = errors.New("списание уже возвращено")
+	// ErrInvalidOrderTransition ошибка недопустимая смена статуса заказа.
+	ErrInvalidOrderTransition = errors.New("недопустимая смена статуса заказа")
 )
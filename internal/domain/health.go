@@ -0,0 +1,42 @@
+package domain
+
+import "context"
+
+// HealthStatus результат проверки состояния.
+type HealthStatus string
+
+const (
+	// HealthUp проверка пройдена.
+	HealthUp HealthStatus = "up"
+	// HealthDown проверка не пройдена.
+	HealthDown HealthStatus = "down"
+)
+
+// HealthCheck проверка зависимости, от которой зависит готовность сервиса принимать запросы.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthCheckResult результат отдельной проверки.
+// Текст ошибки не входит в результат: /readyz доступен без аутентификации, а ошибки зависимостей
+// содержат адреса и детали инфраструктуры. Он пишется в журнал.
+type HealthCheckResult struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMs float64      `json:"latency_ms"`
+}
+
+// HealthReport представляет сводное состояние сервиса.
+type HealthReport struct {
+	Status       HealthStatus                 `json:"status"`
+	ShuttingDown bool                         `json:"shutting_down,omitempty"`
+	Checks       map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthService определяет интерфейс проверок живости и готовности сервиса.
+type HealthService interface {
+	// Liveness сообщает, что процесс работает и обрабатывает запросы.
+	Liveness() *HealthReport
+	// Readiness выполняет проверки зависимостей; после начала остановки сервис не готов без проверок.
+	Readiness(ctx context.Context) *HealthReport
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord представляет сохраненный ответ на запрос с заголовком Idempotency-Key.
+type IdempotencyRecord struct {
+	UserID      int        `db:"user_id"`
+	Key         string     `db:"key"`
+	RequestHash string     `db:"request_hash"`
+	StatusCode  *int       `db:"status_code"` // nil, пока первый запрос еще выполняется
+	ContentType string     `db:"content_type"`
+	Body        []byte     `db:"response_body"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	LockedUntil *time.Time `db:"locked_until"` // окончание аренды ключа выполняющимся запросом
+}
+
+// Completed сообщает, сохранен ли ответ на первый запрос.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != nil
+}
+
+// IdempotencyRepository определяет интерфейс хранилища ключей идемпотентности.
+type IdempotencyRepository interface {
+	// Reserve занимает ключ для нового запроса до record.LockedUntil. Ключ, аренда которого истекла
+	// без сохраненного ответа, занимается заново. Если ключ уже занят и не истек,
+	// возвращает существующую запись и false.
+	Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, bool, error)
+	// Extend продлевает аренду ключа выполняющимся запросом до lockedUntil.
+	Extend(ctx context.Context, userID int, key string, lockedUntil time.Time) error
+	// Complete сохраняет ответ на запрос для последующих повторов.
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release освобождает ключ, чтобы запрос можно было повторить.
+	Release(ctx context.Context, userID int, key string) error
+}
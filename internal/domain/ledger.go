@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// LedgerDirection направление проводки по счету.
+type LedgerDirection string
+
+const (
+	// LedgerDebit списание со счета.
+	LedgerDebit LedgerDirection = "DEBIT"
+	// LedgerCredit зачисление на счет.
+	LedgerCredit LedgerDirection = "CREDIT"
+)
+
+// LedgerReferenceType тип операции, породившей проводку.
+type LedgerReferenceType string
+
+const (
+	// LedgerReferenceOrder начисление баллов за обработанный заказ.
+	LedgerReferenceOrder LedgerReferenceType = "order"
+	// LedgerReferenceWithdrawal списание баллов в счет оплаты заказа.
+	LedgerReferenceWithdrawal LedgerReferenceType = "withdrawal"
+	// LedgerReferenceAdjustment ручная корректировка баланса службой поддержки.
+	LedgerReferenceAdjustment LedgerReferenceType = "adjustment"
+	// LedgerReferenceWithdrawalReversal возврат ранее списанных баллов.
+	LedgerReferenceWithdrawalReversal LedgerReferenceType = "withdrawal_reversal"
+)
+
+const (
+	// LedgerAccountAccrual системный счет-источник начислений от системы расчета баллов.
+	LedgerAccountAccrual = "system:accrual"
+	// LedgerAccountWithdrawal системный счет, на который уходят списанные баллы.
+	LedgerAccountWithdrawal = "system:withdrawal"
+	// LedgerAccountAdjustment системный счет ручных корректировок баланса.
+	LedgerAccountAdjustment = "system:adjustment"
+)
+
+// UserLedgerAccount возвращает имя счета пользователя в журнале проводок.
+func UserLedgerAccount(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// LedgerEntry представляет одну запись журнала проводок.
+type LedgerEntry struct {
+	ID            int64               `db:"id"`
+	PostingID     int64               `db:"posting_id"`
+	Account       string              `db:"account"`
+	UserID        *int                `db:"user_id"`
+	Direction     LedgerDirection     `db:"direction"`
+	Amount        Money               `db:"amount_kop"`
+	ReferenceType LedgerReferenceType `db:"reference_type"`
+	ReferenceID   int                 `db:"reference_id"`
+	CreatedAt     time.Time           `db:"created_at"`
+}
+
+// LedgerDiscrepancy описывает расхождение между журналом проводок и исходными таблицами.
+type LedgerDiscrepancy struct {
+	UserID   int    `db:"user_id"`
+	Check    string `db:"check_name"`
+	Expected Money  `db:"expected_kop"`
+	Actual   Money  `db:"actual_kop"`
+}
+
+// LedgerRepository определяет интерфейс для проверки согласованности журнала проводок.
+type LedgerRepository interface {
+	// FindDiscrepancies сравнивает журнал проводок с таблицами orders, withdrawals, balance_adjustments и balances.
+	FindDiscrepancies() ([]LedgerDiscrepancy, error)
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// LoginThrottleScope определяет, по какому признаку считаются неудачные входы.
+type LoginThrottleScope string
+
+const (
+	// LoginThrottleByLogin неудачные входы в учетную запись.
+	LoginThrottleByLogin LoginThrottleScope = "login"
+	// LoginThrottleByIP неудачные входы с IP-адреса в любые учетные записи.
+	LoginThrottleByIP LoginThrottleScope = "ip"
+)
+
+// LoginThrottle представляет счетчик неудачных входов и блокировку по логину или IP-адресу.
+type LoginThrottle struct {
+	Scope         LoginThrottleScope `db:"scope"`
+	Key           string             `db:"key"`
+	Failures      int                `db:"failures"`
+	LastFailureAt time.Time          `db:"last_failure_at"`
+	LockedUntil   *time.Time         `db:"locked_until"`
+}
+
+// LoginThrottleRepository определяет интерфейс для учета неудачных входов.
+type LoginThrottleRepository interface {
+	// Find возвращает счетчики для логина и IP-адреса, если они есть.
+	Find(login, ip string) ([]LoginThrottle, error)
+	// RecordFailure увеличивает счетчик неудачных входов. Счетчик начинается заново,
+	// если с последней неудачи прошло больше resetAfter.
+	RecordFailure(scope LoginThrottleScope, key string, resetAfter time.Duration) (*LoginThrottle, error)
+	// Lock блокирует вход до указанного времени.
+	Lock(scope LoginThrottleScope, key string, until time.Time) error
+	// Reset сбрасывает счетчик после успешного входа.
+	Reset(scope LoginThrottleScope, key string) error
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Исходы запросов к системе начислений для метрик.
+const (
+	AccrualOutcomeOK          = "200"
+	AccrualOutcomeNoContent   = "204"
+	AccrualOutcomeRateLimited = "429"
+	AccrualOutcomeClientError = "4xx"
+	AccrualOutcomeServerError = "5xx"
+	AccrualOutcomeMalformed   = "malformed"
+	AccrualOutcomeTransport   = "transport_error"
+	AccrualOutcomeCircuitOpen = "circuit_open"
+)
+
+// HTTPMetrics учитывает обработанные HTTP-запросы.
+type HTTPMetrics interface {
+	// ObserveHTTPRequest учитывает запрос по шаблону маршрута, а не по фактическому пути,
+	// чтобы число рядов не зависело от номеров заказов и идентификаторов в URL.
+	ObserveHTTPRequest(method, route string, status int, duration time.Duration)
+}
+
+// AccrualMetrics учитывает запросы к системе начислений.
+type AccrualMetrics interface {
+	// ObserveAccrualRequest учитывает исход запроса (AccrualOutcome*) и его длительность.
+	ObserveAccrualRequest(outcome string, duration time.Duration)
+	// ObserveAccrualRetryAfter учитывает паузу, назначенную системой начислений в Retry-After.
+	ObserveAccrualRetryAfter(wait time.Duration)
+}
+
+// WorkerMetrics учитывает обработку заказов воркером начислений.
+type WorkerMetrics interface {
+	// ObserveOrderFinalized учитывает заказ, получивший окончательный статус,
+	// и время от его загрузки до этого момента.
+	ObserveOrderFinalized(status OrderStatus, lag time.Duration)
+}
+
+// BalanceMetrics учитывает операции с баллами.
+type BalanceMetrics interface {
+	// ObserveAccrualCredited учитывает баллы, начисленные за обработанный заказ.
+	ObserveAccrualCredited(amount Money)
+	// ObserveWithdrawal учитывает списание баллов.
+	ObserveWithdrawal(amount Money)
+	// ObserveWithdrawalRejected учитывает отклоненный запрос на списание.
+	ObserveWithdrawalRejected(reason string)
+	// ObserveAdjustment учитывает корректировку баланса службой поддержки.
+	ObserveAdjustment(kind LedgerReferenceType, direction LedgerDirection, amount Money)
+}
+
+// OrderQueueStats состояние очереди заказов.
+type OrderQueueStats struct {
+	Counts           map[OrderStatus]int // количество заказов по статусам
+	OldestPendingAge time.Duration       // возраст самого старого заказа без окончательного статуса
+}
+
+// OrderQueueSource предоставляет состояние очереди заказов для метрик.
+type OrderQueueSource interface {
+	QueueStats(ctx context.Context) (*OrderQueueStats, error)
+}
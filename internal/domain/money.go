@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// KopPerRuble количество копеек в рубле.
+	KopPerRuble = 100
+)
+
+var (
+	// ErrInvalidMoney ошибка неверный формат денежной суммы.
+	ErrInvalidMoney = errors.New("неверный формат денежной суммы")
+	// ErrMoneyPrecision ошибка сумма задана точнее, чем до копейки.
+	ErrMoneyPrecision = errors.New("сумма не может содержать больше двух знаков после запятой")
+	// ErrMoneyOverflow ошибка сумма не помещается в int64 копеек.
+	ErrMoneyOverflow = errors.New("слишком большая сумма")
+
+	// moneyPattern соответствует грамматике чисел JSON.
+	moneyPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// Money представляет денежную сумму (баллы) в копейках.
+// В JSON сумма представлена десятичным числом в рублях без потери точности.
+type Money int64
+
+// NewMoney создает сумму из рублей и копеек.
+func NewMoney(rub, kop int64) Money {
+	return Money(rub*KopPerRuble + kop)
+}
+
+// ParseMoney разбирает десятичную запись суммы в рублях, например "729.98".
+// Значения, которые нельзя точно представить в копейках, отклоняются.
+func ParseMoney(s string) (Money, error) {
+	if !moneyPattern.MatchString(s) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	// big.Rat разбирает десятичную запись точно, без двоичного округления float64
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	r.Mul(r, big.NewRat(KopPerRuble, 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w: %q", ErrMoneyPrecision, s)
+	}
+
+	kop := r.Num()
+	if !kop.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrMoneyOverflow, s)
+	}
+
+	return Money(kop.Int64()), nil
+}
+
+// Kopecks возвращает сумму в копейках.
+func (m Money) Kopecks() int64 {
+	return int64(m)
+}
+
+// String возвращает сумму в рублях в десятичной записи без лишних нулей: "500", "500.5", "500.05".
+func (m Money) String() string {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-(m + 1)) + 1 // без переполнения для math.MinInt64
+	}
+
+	rub := abs / KopPerRuble
+	kop := abs % KopPerRuble
+	switch {
+	case kop == 0:
+		return sign + strconv.FormatUint(rub, 10)
+	case kop%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, rub, kop/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, rub, kop)
+	}
+}
+
+// Float64 возвращает приблизительное значение суммы в рублях, например для метрик.
+func (m Money) Float64() float64 {
+	return float64(m) / KopPerRuble
+}
+
+// MarshalJSON реализует интерфейс json.Marshaler для Money.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler для Money.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value реализует интерфейс driver.Valuer для Money.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan реализует интерфейс sql.Scanner для Money.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return fmt.Errorf("unable to scan fractional %v into Money", v)
+		}
+		*m = Money(v)
+	default:
+		return fmt.Errorf("unable to scan %T into Money", value)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+func TestMoneyStringRoundTrip(t *testing.T) {
+	roundTrip := func(kop int64) bool {
+		m := Money(kop)
+		parsed, err := ParseMoney(m.String())
+		return err == nil && parsed == m
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+
+	for _, kop := range []int64{0, 1, -1, 10, 99, 100, 101, math.MaxInt64, math.MinInt64} {
+		if !roundTrip(kop) {
+			t.Errorf("round trip failed for %d kop (%q)", kop, Money(kop).String())
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	type payload struct {
+		Sum     Money  `json:"sum"`
+		Accrual *Money `json:"accrual,omitempty"`
+	}
+
+	roundTrip := func(sum, accrual int64) bool {
+		a := Money(accrual)
+		in := payload{Sum: Money(sum), Accrual: &a}
+
+		data, err := json.Marshal(in)
+		if err != nil {
+			return false
+		}
+		var out payload
+		if err = json.Unmarshal(data, &out); err != nil {
+			return false
+		}
+		return out.Sum == in.Sum && out.Accrual != nil && *out.Accrual == *in.Accrual
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr error
+	}{
+		{input: "729.98", want: 72998},
+		{input: "500", want: 50000},
+		{input: "500.5", want: 50050},
+		{input: "0.01", want: 1},
+		{input: "-0.01", want: -1},
+		{input: "1e2", want: 10000},
+		{input: "1.50e1", want: 1500},
+		{input: "92233720368547758.07", want: math.MaxInt64},
+		{input: "1.005", wantErr: ErrMoneyPrecision},
+		{input: "0.001", wantErr: ErrMoneyPrecision},
+		{input: "1e-3", wantErr: ErrMoneyPrecision},
+		{input: "1e30", wantErr: ErrMoneyOverflow},
+		{input: "92233720368547758.08", wantErr: ErrMoneyOverflow},
+		{input: "NaN", wantErr: ErrInvalidMoney},
+		{input: "Inf", wantErr: ErrInvalidMoney},
+		{input: "-Infinity", wantErr: ErrInvalidMoney},
+		{input: "", wantErr: ErrInvalidMoney},
+		{input: "abc", wantErr: ErrInvalidMoney},
+		{input: `"10"`, wantErr: ErrInvalidMoney},
+		{input: "1,5", wantErr: ErrInvalidMoney},
+		{input: "01", wantErr: ErrInvalidMoney},
+		{input: ".5", wantErr: ErrInvalidMoney},
+		{input: "0x10", wantErr: ErrInvalidMoney},
+		{input: "1/2", wantErr: ErrInvalidMoney},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseMoney(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSONRejects(t *testing.T) {
+	for _, input := range []string{`1.005`, `1e30`, `"NaN"`, `"12.5"`, `true`} {
+		var m Money
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("json.Unmarshal(%s) = %v, want error", input, m)
+		}
+	}
+}
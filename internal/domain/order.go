@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"database/sql/driver"
 	"fmt"
 	"time"
@@ -18,12 +19,42 @@ const (
 	OrderStatusInvalid OrderStatus = "INVALID"
 	// OrderStatusProcessed данные по заказу проверены и информация о расчете успешно получена.
 	OrderStatusProcessed OrderStatus = "PROCESSED"
+	// OrderStatusRegistered заказ зарегистрирован в системе начислений, но расчет не начат.
+	// Статус приходит только от системы начислений и не сохраняется в заказе.
+	OrderStatusRegistered OrderStatus = "REGISTERED"
 )
 
-const (
-	// KopPerRuble количество копеек в рубле.
-	KopPerRuble = 100
-)
+// IsFinal сообщает, является ли статус окончательным.
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusProcessed || s == OrderStatusInvalid
+}
+
+// orderTransitions допустимые переходы между статусами заказа. Статус меняется только вперед:
+// NEW -> PROCESSING -> PROCESSED/INVALID, расчет может завершиться и без промежуточного PROCESSING.
+// Из окончательных статусов переходов нет.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusNew:        {OrderStatusProcessing, OrderStatusProcessed, OrderStatusInvalid},
+	OrderStatusProcessing: {OrderStatusProcessed, OrderStatusInvalid},
+}
+
+// CanTransitionTo сообщает, может ли заказ перейти из статуса s в статус next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateOrderTransition проверяет смену статуса заказа и возвращает ErrInvalidOrderTransition,
+// если переход не предусмотрен.
+func ValidateOrderTransition(from, to OrderStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidOrderTransition, from, to)
+	}
+	return nil
+}
 
 // Value реализует интерфейс driver.Valuer для OrderStatus.
 func (s OrderStatus) Value() (driver.Value, error) {
@@ -46,59 +77,77 @@ func (s *OrderStatus) Scan(value interface{}) error {
 
 // Order представляет заказ в системе.
 type Order struct {
-	ID         int         `json:"-"                 db:"id"`
-	Number     string      `json:"number"            db:"number"`
-	UserID     int         `json:"-"                 db:"user_id"`
-	Status     OrderStatus `json:"status"            db:"status"`
-	Accrual    *int64      `json:"-"                 db:"accrual,omitempty"` // сумма начисленных баллов в копейках
-	AccrualRub *float64    `json:"accrual,omitempty" db:"-"`                 // сумма начисленных баллов в рублях для JSON
-	UploadedAt time.Time   `json:"uploaded_at"       db:"uploaded_at"`
-}
-
-// SetAccrual устанавливает сумму начисления в копейках и автоматически обновляет сумму в рублях.
-func (o *Order) SetAccrual(kop int64) {
-	o.Accrual = &kop
-	o.CalculateAccrualRub()
-}
-
-// CalculateAccrualRub вычисляет сумму в рублях на основе суммы в копейках.
-func (o *Order) CalculateAccrualRub() {
-	if o.Accrual != nil {
-		accrualRub := float64(*o.Accrual) / KopPerRuble
-		o.AccrualRub = &accrualRub
-	}
-}
-
-// GetAccrualRub возвращает сумму начисленных баллов в рублях.
-func (o *Order) GetAccrualRub() float64 {
-	if o.Accrual == nil {
-		return 0
-	}
-	return float64(*o.Accrual) / KopPerRuble
+	ID            int         `json:"-"                 db:"id"`
+	Number        string      `json:"number"            db:"number"`
+	UserID        int         `json:"-"                 db:"user_id"`
+	Status        OrderStatus `json:"status"            db:"status"`
+	Accrual       *Money      `json:"accrual,omitempty" db:"accrual"` // сумма начисленных баллов
+	UploadedAt    time.Time   `json:"uploaded_at"       db:"uploaded_at"`
+	LockedUntil   *time.Time  `json:"-"                 db:"locked_until"`    // окончание аренды заказа воркером
+	Attempts      int         `json:"-"                 db:"attempts"`        // количество запросов в систему начислений
+	LastError     *string     `json:"-"                 db:"last_error"`      // причина последней неудачной попытки
+	NextAttemptAt time.Time   `json:"-"                 db:"next_attempt_at"` // время следующего запроса
+	FailureReason *string     `json:"-"                 db:"failure_reason"`  // причина прекращения опроса
+	TraceParent   *string     `json:"-"                 db:"trace_parent"`    // трассировка запроса загрузки заказа
 }
 
 // OrderRepository определяет интерфейс для доступа к данным заказов.
 type OrderRepository interface {
 	// Create создает новый заказ.
-	Create(order *Order) error
+	Create(ctx context.Context, order *Order) error
 	// FindByNumber ищет заказ по номеру.
-	FindByNumber(number string) (*Order, error)
-	// FindByUserID возвращает все заказы пользователя.
-	FindByUserID(userID int) ([]Order, error)
-	// FindByStatus возвращает заказы с указанными статусами.
-	FindByStatus(statuses []OrderStatus) ([]Order, error)
-	// UpdateStatus обновляет статус заказа.
-	UpdateStatus(orderID int, status OrderStatus) error
-	// UpdateAccrual обновляет сумму начисленных баллов за заказ.
-	UpdateAccrual(orderID int, accrualKop int64) error
+	FindByNumber(ctx context.Context, number string) (*Order, error)
+	// FindByUserID возвращает заказы пользователя по фильтру.
+	FindByUserID(ctx context.Context, userID int, filter OrderFilter) ([]Order, error)
+	// FindStatusHistory возвращает историю смены статусов заказа в порядке изменений.
+	FindStatusHistory(ctx context.Context, orderID int) ([]OrderStatusChange, error)
+	// ClaimDue захватывает аренду на пачку заказов с указанными статусами, время опроса которых наступило.
+	// Заказы, захваченные другими воркерами, пропускаются, поэтому воркеры получают непересекающиеся пачки.
+	// Заказы с истекшей арендой снова становятся доступны.
+	ClaimDue(ctx context.Context, statuses []OrderStatus, limit int, lease time.Duration) ([]Order, error)
+	// ReleaseClaim снимает аренду с заказа после его обработки.
+	ReleaseClaim(ctx context.Context, orderID int) error
+	// ScheduleRetry фиксирует неудачную попытку и назначает время следующего опроса.
+	ScheduleRetry(ctx context.Context, orderID int, nextAttemptAt time.Time, lastError string) error
+	// MarkFailed переводит заказ в окончательный статус INVALID с указанием причины.
+	// Для заказа в окончательном статусе возвращает ErrInvalidOrderTransition.
+	MarkFailed(ctx context.Context, orderID int, reason string) error
+	// ApplyAccrual применяет ответ системы начислений в одной транзакции: меняет статус, сохраняет
+	// начисление, зачисляет баллы для PROCESSED и записывает переход в историю статусов.
+	// Повтор текущего неокончательного статуса ничего не меняет, остальные переходы вне
+	// NEW -> PROCESSING -> PROCESSED/INVALID отклоняются с ErrInvalidOrderTransition.
+	// Окончательный ответ учитывается в количестве запросов к системе начислений.
+	ApplyAccrual(ctx context.Context, orderID int, status OrderStatus, accrual *Money) error
+	// QueueStats возвращает количество заказов по статусам и возраст самого старого необработанного заказа.
+	QueueStats(ctx context.Context) (*OrderQueueStats, error)
 }
 
 // OrderService определяет интерфейс для бизнес-логики работы с заказами.
 type OrderService interface {
 	// Register регистрирует новый заказ для пользователя.
-	Register(userID int, number string) error
-	// GetOrders возвращает список заказов пользователя.
-	GetOrders(userID int) ([]Order, error)
+	Register(ctx context.Context, userID int, number string, meta RequestMeta) error
+	// GetOrders возвращает страницу заказов пользователя и курсор следующей страницы.
+	GetOrders(ctx context.Context, userID int, filter OrderFilter) (*OrderPage, error)
+	// GetOrder возвращает заказ пользователя по номеру вместе с историей обработки.
+	GetOrder(ctx context.Context, userID int, number string) (*OrderDetails, error)
+}
+
+// OrderStatusChange представляет запись истории смены статуса заказа.
+type OrderStatusChange struct {
+	From      OrderStatus `json:"from"              db:"from_status"`
+	To        OrderStatus `json:"to"                db:"to_status"`
+	Accrual   *Money      `json:"accrual,omitempty" db:"accrual"`
+	Reason    *string     `json:"reason,omitempty"  db:"reason"`  // причина перехода в INVALID
+	Attempt   *int        `json:"attempt,omitempty" db:"attempt"` // номер запроса в систему начислений
+	ChangedAt time.Time   `json:"changed_at"        db:"created_at"`
+}
+
+// OrderDetails представляет заказ вместе с историей обработки.
+type OrderDetails struct {
+	Order
+	AccrualRequests int                 `json:"accrual_requests"`         // количество запросов в систему начислений
+	FailureReason   *string             `json:"failure_reason,omitempty"` // причина прекращения опроса
+	History         []OrderStatusChange `json:"history"`
 }
 
 // OrderRequest представляет данные запроса на регистрацию заказа.
@@ -110,5 +159,5 @@ type OrderRequest struct {
 type OrderAccrual struct {
 	Order   string      `json:"order"`
 	Status  OrderStatus `json:"status"`
-	Accrual *float64    `json:"accrual,omitempty"`
+	Accrual *Money      `json:"accrual,omitempty"`
 }
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor ошибка неверный курсор страницы.
+var ErrInvalidCursor = errors.New("неверный курсор страницы")
+
+// SortOrder порядок сортировки списка по времени.
+type SortOrder string
+
+const (
+	// SortNewestFirst сначала новые записи (по умолчанию).
+	SortNewestFirst SortOrder = "desc"
+	// SortOldestFirst сначала старые записи.
+	SortOldestFirst SortOrder = "asc"
+)
+
+// PageCursor позиция в списке, отсортированном по времени и идентификатору.
+// Следующая страница начинается сразу после записи с этими значениями.
+// Курсор хранит порядок сортировки, в котором он выдан, и действует только в этом порядке.
+type PageCursor struct {
+	Sort SortOrder
+	Time time.Time
+	ID   int
+}
+
+// Encode возвращает непрозрачное строковое представление курсора для передачи клиенту.
+func (c PageCursor) Encode() string {
+	raw := string(c.Sort) + "|" + c.Time.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParsePageCursor разбирает курсор, полученный от клиента.
+func ParsePageCursor(value string) (*PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	sort := SortOrder(parts[0])
+	if sort != SortNewestFirst && sort != SortOldestFirst {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidCursor, sort)
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	return &PageCursor{Sort: sort, Time: t, ID: id}, nil
+}
+
+// ListFilter общие условия выборки списков пользователя с постраничным выводом.
+type ListFilter struct {
+	Since *time.Time  // начало периода
+	Until *time.Time  // конец периода, не включается
+	Sort  SortOrder   // порядок сортировки (пусто - сначала новые)
+	After *PageCursor // курсор: записи после указанной (nil - с начала списка)
+	Limit int         // количество записей (0 - без ограничения, если не задан курсор)
+}
+
+// OrderFilter условия выборки заказов пользователя.
+type OrderFilter struct {
+	ListFilter
+	Statuses []OrderStatus // статусы заказов (пусто - любые)
+}
+
+// OrderPage страница заказов пользователя.
+type OrderPage struct {
+	Orders     []Order
+	NextCursor string // пусто, если заказов больше нет
+}
+
+// WithdrawalStatus состояние списания для фильтрации списка.
+type WithdrawalStatus string
+
+const (
+	// WithdrawalStatusActive списание действует.
+	WithdrawalStatusActive WithdrawalStatus = "active"
+	// WithdrawalStatusReversed списание возвращено.
+	WithdrawalStatusReversed WithdrawalStatus = "reversed"
+)
+
+// WithdrawalFilter условия выборки списаний пользователя.
+type WithdrawalFilter struct {
+	ListFilter
+	Status WithdrawalStatus // состояние списаний (пусто - любые)
+}
+
+// WithdrawalPage страница списаний пользователя.
+type WithdrawalPage struct {
+	Withdrawals []Withdrawal
+	NextCursor  string // пусто, если списаний больше нет
+}
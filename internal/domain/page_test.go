@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	for _, sort := range []SortOrder{SortNewestFirst, SortOldestFirst} {
+		in := PageCursor{Sort: sort, Time: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC), ID: 42}
+
+		out, err := ParsePageCursor(in.Encode())
+		if err != nil {
+			t.Fatalf("ParsePageCursor(%q) unexpected error: %v", in.Encode(), err)
+		}
+		if out.Sort != in.Sort || !out.Time.Equal(in.Time) || out.ID != in.ID {
+			t.Errorf("round trip = %+v, want %+v", *out, in)
+		}
+	}
+}
+
+func TestParsePageCursorRejects(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	for _, value := range []string{
+		"",
+		"!!!",
+		encode("2024-03-01T12:30:00Z|42"),
+		encode("up|2024-03-01T12:30:00Z|42"),
+		encode("desc|yesterday|42"),
+		encode("desc|2024-03-01T12:30:00Z|x"),
+	} {
+		if _, err := ParsePageCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParsePageCursor(%q) error = %v, want %v", value, err, ErrInvalidCursor)
+		}
+	}
+}
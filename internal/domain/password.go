@@ -0,0 +1,17 @@
+package domain
+
+// PasswordHasher определяет интерфейс хеширования паролей.
+type PasswordHasher interface {
+	// Hash вычисляет хеш пароля текущим алгоритмом.
+	Hash(password string) (string, error)
+	// Verify проверяет пароль по хешу.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash сообщает, что хеш нужно пересчитать текущим алгоритмом.
+	NeedsRehash(hash string) bool
+}
+
+// ChangePasswordRequest представляет данные запроса на смену пароля.
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
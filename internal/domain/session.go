@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid ошибка refresh-токен не найден или истек.
+	ErrRefreshTokenInvalid = errors.New("неверный или истекший refresh-токен")
+	// ErrRefreshTokenReused ошибка refresh-токен уже был обменян; сессия отозвана.
+	ErrRefreshTokenReused = errors.New("повторное использование refresh-токена, сессия отозвана")
+	// ErrSessionRevoked ошибка сессия отозвана.
+	ErrSessionRevoked = errors.New("сессия отозвана")
+)
+
+// Причины отзыва сессии.
+const (
+	SessionRevokeLogout         = "logout"
+	SessionRevokeLogoutAll      = "logout_all"
+	SessionRevokeTokenReused    = "refresh_token_reused"
+	SessionRevokePasswordChange = "password_change"
+	SessionRevokeUserBlocked    = "user_blocked"
+)
+
+// Session представляет сессию пользователя, к которой привязаны access- и refresh-токены.
+type Session struct {
+	ID           int64      `db:"id"`
+	UserID       int        `db:"user_id"`
+	CreatedAt    time.Time  `db:"created_at"`
+	LastUsedAt   time.Time  `db:"last_used_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+	RevokeReason *string    `db:"revoke_reason"`
+}
+
+// RefreshRequest представляет данные запроса на обновление токенов.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// SessionRepository определяет интерфейс для доступа к сессиям и refresh-токенам.
+// Токены передаются в репозиторий только в виде хеша.
+type SessionRepository interface {
+	// Create создает сессию с первым refresh-токеном.
+	Create(userID int, tokenHash string, tokenExpiresAt time.Time) (*Session, error)
+	// Rotate обменивает refresh-токен на новый в рамках той же сессии.
+	// Повторное предъявление уже обменянного токена отзывает сессию и возвращает ErrRefreshTokenReused.
+	Rotate(tokenHash, newTokenHash string, newExpiresAt time.Time) (*Session, error)
+	// Authorize проверяет, что сессия пользователя не отозвана, а сам пользователь не заблокирован,
+	// и возвращает его текущую роль. Роль из токена не используется: снятие роли действует сразу.
+	Authorize(sessionID int64, userID int) (role UserRole, active bool, err error)
+	// Revoke отзывает сессию пользователя.
+	Revoke(sessionID int64, userID int, reason string) error
+	// RevokeAll отзывает все сессии пользователя.
+	RevokeAll(userID int, reason string) error
+}
@@ -1,33 +1,126 @@
 package domain
 
 import (
+	"context"
+	"errors"
 	"time"
 )
 
+// ErrUserBlocked ошибка учетная запись заблокирована.
+var ErrUserBlocked = errors.New("учетная запись заблокирована")
+
+// UserRole роль пользователя, определяющая доступ к маршрутам API.
+type UserRole string
+
+const (
+	// RoleUser обычный пользователь.
+	RoleUser UserRole = "user"
+	// RoleAdmin сотрудник поддержки с доступом к /api/admin.
+	RoleAdmin UserRole = "admin"
+)
+
 // User представляет пользователя в системе.
 type User struct {
-	ID           int       `json:"-"     db:"id"`
-	Login        string    `json:"login" db:"login"`
-	PasswordHash string    `json:"-"     db:"password_hash"`
-	CreatedAt    time.Time `json:"-"     db:"created_at"`
-	UpdatedAt    time.Time `json:"-"     db:"updated_at"`
+	ID            int        `json:"-"     db:"id"`
+	Login         string     `json:"login" db:"login"`
+	PasswordHash  string     `json:"-"     db:"password_hash"`
+	CreatedAt     time.Time  `json:"-"     db:"created_at"`
+	UpdatedAt     time.Time  `json:"-"     db:"updated_at"`
+	Role          UserRole   `json:"-"     db:"role"`
+	BlockedAt     *time.Time `json:"-"     db:"blocked_at"`
+	BlockedReason *string    `json:"-"     db:"blocked_reason"`
+}
+
+// Blocked сообщает, что учетная запись заблокирована.
+func (u *User) Blocked() bool {
+	return u.BlockedAt != nil
 }
 
-// AuthToken представляет данные авторизационного токена.
+// UserInfo представляет сведения о пользователе для администраторов.
+type UserInfo struct {
+	ID            int        `json:"id"                       db:"id"`
+	Login         string     `json:"login"                    db:"login"`
+	Role          UserRole   `json:"role"                     db:"role"`
+	CreatedAt     time.Time  `json:"created_at"               db:"created_at"`
+	BlockedAt     *time.Time `json:"blocked_at,omitempty"     db:"blocked_at"`
+	BlockedReason *string    `json:"blocked_reason,omitempty" db:"blocked_reason"`
+}
+
+// AuthToken представляет пару токенов: короткоживущий access-токен и refresh-токен для его обновления.
 type AuthToken struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // время жизни access-токена в секундах
 }
 
 // UserRepository определяет интерфейс для доступа к данным пользователей.
 type UserRepository interface {
-	Create(user *User) error
-	FindByLogin(login string) (*User, error)
+	Create(ctx context.Context, user *User) error
+	FindByLogin(ctx context.Context, login string) (*User, error)
+	FindByID(ctx context.Context, id int) (*User, error)
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	// ChangePassword сохраняет новый хеш пароля и отзывает все сессии пользователя в одной транзакции.
+	ChangePassword(ctx context.Context, userID int, passwordHash string, revokeReason string) error
+	// Search ищет пользователей по части логина (пустой запрос - все пользователи).
+	Search(ctx context.Context, query string, limit, offset int) ([]UserInfo, error)
+	// SetBlocked блокирует или разблокирует пользователя; sql.ErrNoRows, если пользователя нет.
+	SetBlocked(ctx context.Context, userID int, blocked bool, reason string) error
+	// SetRole назначает роль пользователю; sql.ErrNoRows, если пользователя нет.
+	SetRole(ctx context.Context, userID int, role UserRole) error
 }
 
 // UserService определяет интерфейс для бизнес-логики работы с пользователями.
 type UserService interface {
-	Register(login, password string) (*AuthToken, error)
-	Authenticate(login, password string) (*AuthToken, error)
+	Register(ctx context.Context, login, password string, meta RequestMeta) (*AuthToken, error)
+	Authenticate(ctx context.Context, login, password string, meta RequestMeta) (*AuthToken, error)
+	// Refresh обменивает refresh-токен на новую пару токенов.
+	Refresh(ctx context.Context, refreshToken string, meta RequestMeta) (*AuthToken, error)
+	// Logout отзывает текущую сессию пользователя.
+	Logout(ctx context.Context, userID int, sessionID int64, meta RequestMeta) error
+	// LogoutAll отзывает все сессии пользователя.
+	LogoutAll(ctx context.Context, userID int, meta RequestMeta) error
+	// ChangePassword меняет пароль и отзывает все сессии пользователя, кроме новой.
+	ChangePassword(ctx context.Context, userID int, oldPassword, newPassword string, meta RequestMeta) (*AuthToken, error)
+}
+
+// BlockUserRequest представляет данные запроса на блокировку пользователя.
+type BlockUserRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// AdminService определяет интерфейс для операций поддержки над учетными записями пользователей.
+type AdminService interface {
+	// SearchUsers ищет пользователей по части логина.
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]UserInfo, error)
+	// GetUserOrders возвращает заказы пользователя.
+	GetUserOrders(ctx context.Context, userID int) ([]Order, error)
+	// GetUserWithdrawals возвращает списания пользователя.
+	GetUserWithdrawals(ctx context.Context, userID int) ([]WithdrawalRecord, error)
+	// GetUserAdjustments возвращает корректировки баланса пользователя.
+	GetUserAdjustments(ctx context.Context, userID int) ([]Adjustment, error)
+	// GetUserBalance возвращает баланс пользователя.
+	GetUserBalance(ctx context.Context, userID int) (*Balance, error)
+	// AdjustBalance зачисляет или списывает баллы пользователя от имени оператора.
+	AdjustBalance(
+		ctx context.Context,
+		operatorID,
+		userID int,
+		req *AdjustmentRequest,
+		meta RequestMeta,
+	) (*Adjustment, error)
+	// ReverseWithdrawal возвращает пользователю баллы по списанию от имени оператора.
+	ReverseWithdrawal(
+		ctx context.Context,
+		operatorID,
+		userID,
+		withdrawalID int,
+		reason string,
+		meta RequestMeta,
+	) (*Adjustment, error)
+	// BlockUser блокирует пользователя и отзывает все его сессии.
+	BlockUser(ctx context.Context, operatorID, userID int, reason string, meta RequestMeta) error
+	// UnblockUser снимает блокировку с пользователя.
+	UnblockUser(ctx context.Context, operatorID, userID int, meta RequestMeta) error
 }
 
 // RegisterRequest представляет данные запроса на регистрацию.
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/service"
+)
+
+// AdminHandler обработчик запросов службы поддержки.
+type AdminHandler struct {
+	adminService domain.AdminService
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler.
+func NewAdminHandler(adminService domain.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// SearchUsers возвращает пользователей, логин которых содержит строку поиска.
+// @Summary Поиск пользователей.
+// @Tags admin
+// @Produce json
+// @Param q query string false "Часть логина"
+// @Param limit query int false "Количество записей (по умолчанию 50, не более 500)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} domain.UserInfo "Найденные пользователи"
+// @Failure 400 "Неверные параметры запроса"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 403 "Недостаточно прав"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users [get]
+func (h *AdminHandler) SearchUsers(c echo.Context) error {
+	limit, limitErr := queryInt(c, "limit")
+	offset, offsetErr := queryInt(c, "offset")
+	if limitErr != nil || offsetErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверные параметры запроса")
+	}
+
+	users, err := h.adminService.SearchUsers(c.Request().Context(), c.QueryParam("q"), limit, offset)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// GetUserOrders возвращает заказы пользователя.
+// @Summary Заказы пользователя.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Success 200 {array} domain.Order "Заказы пользователя"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/orders [get]
+func (h *AdminHandler) GetUserOrders(c echo.Context) error {
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	orders, err := h.adminService.GetUserOrders(c.Request().Context(), userID)
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusOK, orders)
+}
+
+// GetUserWithdrawals возвращает списания пользователя.
+// @Summary Списания пользователя.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Success 200 {array} domain.WithdrawalRecord "Списания пользователя"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/withdrawals [get]
+func (h *AdminHandler) GetUserWithdrawals(c echo.Context) error {
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	withdrawals, err := h.adminService.GetUserWithdrawals(c.Request().Context(), userID)
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusOK, withdrawals)
+}
+
+// GetUserAdjustments возвращает корректировки баланса пользователя.
+// @Summary Корректировки баланса пользователя.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Success 200 {array} domain.Adjustment "Корректировки и возвраты списаний"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/adjustments [get]
+func (h *AdminHandler) GetUserAdjustments(c echo.Context) error {
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	adjustments, err := h.adminService.GetUserAdjustments(c.Request().Context(), userID)
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusOK, adjustments)
+}
+
+// AdjustBalance зачисляет или списывает баллы пользователя.
+// @Summary Корректировка баланса пользователя.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Param request body domain.AdjustmentRequest true "Сумма (отрицательная для списания) и причина"
+// @Success 201 {object} domain.Adjustment "Корректировка проведена"
+// @Failure 400 "Неверный формат запроса"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 409 "Недостаточно средств для списания"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/adjustments [post]
+// @Description Корректировка попадает в историю баланса пользователя; в ней сохраняется оператор, выполнивший операцию.
+func (h *AdminHandler) AdjustBalance(c echo.Context) error {
+	operatorID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.AdjustmentRequest
+	if bindErr := c.Bind(&req); bindErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса")
+	}
+
+	if validateErr := c.Validate(&req); validateErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
+	}
+
+	adjustment, err := h.adminService.AdjustBalance(c.Request().Context(), operatorID, userID, &req, requestMeta(c))
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusCreated, adjustment)
+}
+
+// ReverseWithdrawal возвращает баллы по списанию.
+// @Summary Возврат списания.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Param withdrawal_id path int true "Идентификатор списания"
+// @Param request body domain.WithdrawalReversalRequest true "Причина возврата"
+// @Success 201 {object} domain.Adjustment "Списание возвращено"
+// @Failure 400 "Неверный формат запроса"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь или списание не найдены"
+// @Failure 409 "Списание уже возвращено"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/withdrawals/{withdrawal_id}/reverse [post]
+func (h *AdminHandler) ReverseWithdrawal(c echo.Context) error {
+	operatorID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	withdrawalID, convErr := strconv.Atoi(c.Param("withdrawal_id"))
+	if convErr != nil || withdrawalID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный идентификатор списания")
+	}
+
+	var req domain.WithdrawalReversalRequest
+	if bindErr := c.Bind(&req); bindErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса")
+	}
+
+	if validateErr := c.Validate(&req); validateErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
+	}
+
+	adjustment, err := h.adminService.ReverseWithdrawal(
+		c.Request().Context(),
+		operatorID,
+		userID,
+		withdrawalID,
+		req.Reason,
+		requestMeta(c),
+	)
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusCreated, adjustment)
+}
+
+// GetUserBalance возвращает баланс пользователя.
+// @Summary Баланс пользователя.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Идентификатор пользователя"
+// @Success 200 {object} domain.Balance "Баланс пользователя"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/balance [get]
+func (h *AdminHandler) GetUserBalance(c echo.Context) error {
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	balance, err := h.adminService.GetUserBalance(c.Request().Context(), userID)
+	if err != nil {
+		return adminError(err)
+	}
+
+	return c.JSON(http.StatusOK, balance)
+}
+
+// BlockUser блокирует пользователя.
+// @Summary Блокировка пользователя.
+// @Tags admin
+// @Accept json
+// @Param id path int true "Идентификатор пользователя"
+// @Param request body domain.BlockUserRequest true "Причина блокировки"
+// @Success 204 "Пользователь заблокирован"
+// @Failure 400 "Неверный формат запроса"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/block [post]
+// @Description Блокирует пользователя и отзывает все его сессии; выданные токены перестают приниматься сразу.
+func (h *AdminHandler) BlockUser(c echo.Context) error {
+	operatorID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.BlockUserRequest
+	if bindErr := c.Bind(&req); bindErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса")
+	}
+
+	if validateErr := c.Validate(&req); validateErr != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
+	}
+
+	blockErr := h.adminService.BlockUser(
+		c.Request().Context(),
+		operatorID,
+		userID,
+		req.Reason,
+		requestMeta(c),
+	)
+	if blockErr != nil {
+		return adminError(blockErr)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnblockUser снимает блокировку с пользователя.
+// @Summary Разблокировка пользователя.
+// @Tags admin
+// @Param id path int true "Идентификатор пользователя"
+// @Success 204 "Блокировка снята"
+// @Failure 403 "Недостаточно прав"
+// @Failure 404 "Пользователь не найден"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{id}/unblock [post]
+func (h *AdminHandler) UnblockUser(c echo.Context) error {
+	operatorID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	userID, err := pathUserID(c)
+	if err != nil {
+		return err
+	}
+
+	unblockErr := h.adminService.UnblockUser(
+		c.Request().Context(),
+		operatorID,
+		userID,
+		requestMeta(c),
+	)
+	if unblockErr != nil {
+		return adminError(unblockErr)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// pathUserID извлекает идентификатор пользователя из пути запроса.
+func pathUserID(c echo.Context) (int, error) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Неверный идентификатор пользователя")
+	}
+	return userID, nil
+}
+
+// queryInt возвращает целочисленный параметр запроса или 0, если параметр не задан.
+func queryInt(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// adminError преобразует ошибку сервиса в HTTP-ответ.
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Пользователь не найден")
+	case errors.Is(err, service.ErrWithdrawalNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Списание не найдено")
+	case errors.Is(err, service.ErrWithdrawalReversed):
+		return echo.NewHTTPError(http.StatusConflict, "Списание уже возвращено")
+	case errors.Is(err, service.ErrInsufficientFunds):
+		return echo.NewHTTPError(http.StatusConflict, "Недостаточно средств")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+}
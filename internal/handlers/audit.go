@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+// AuditHandler обработчик запросов к журналу аудита.
+type AuditHandler struct {
+	auditService domain.AuditService
+}
+
+// NewAuditHandler создает новый экземпляр AuditHandler.
+func NewAuditHandler(auditService domain.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// FindEvents возвращает события журнала аудита по фильтру, от новых к старым.
+// @Summary Журнал аудита.
+// @Tags admin
+// @Produce json
+// @Param actor_id query int false "Пользователь, выполнивший действие"
+// @Param action query string false "Действие, например user.login"
+// @Param target_type query string false "Тип объекта: user, order, withdrawal, adjustment"
+// @Param target_id query string false "Идентификатор объекта"
+// @Param ip query string false "IP-адрес клиента"
+// @Param since query string false "Начало периода (RFC 3339)"
+// @Param until query string false "Конец периода (RFC 3339, не включается)"
+// @Param cursor query string false "Курсор из next_cursor предыдущей страницы"
+// @Param limit query int false "Размер страницы (по умолчанию 50, не более 500)"
+// @Success 200 {object} domain.AuditPage "Страница событий"
+// @Failure 400 "Неверные параметры запроса"
+// @Failure 403 "Недостаточно прав"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/audit [get]
+func (h *AuditHandler) FindEvents(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверные параметры запроса")
+	}
+
+	page, err := h.auditService.Find(filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// VerifyChain проверяет цепочку хешей журнала аудита.
+// @Summary Проверка целостности журнала аудита.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.AuditChainViolation "Нарушения цепочки; пустой список, если журнал не изменялся"
+// @Failure 403 "Недостаточно прав"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/admin/audit/verify [get]
+func (h *AuditHandler) VerifyChain(c echo.Context) error {
+	violations, err := h.auditService.VerifyChain()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.JSON(http.StatusOK, violations)
+}
+
+// parseAuditFilter разбирает параметры запроса к журналу аудита.
+func parseAuditFilter(c echo.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{
+		Action:     domain.AuditAction(c.QueryParam("action")),
+		TargetType: c.QueryParam("target_type"),
+		TargetID:   c.QueryParam("target_id"),
+		IP:         c.QueryParam("ip"),
+	}
+
+	if value := c.QueryParam("actor_id"); value != "" {
+		actorID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.QueryParam(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, err
+			}
+			*target = &parsed
+		}
+	}
+
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		filter.BeforeID = cursor
+	}
+
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return filter, err
+	}
+	filter.Limit = limit
+
+	return filter, nil
+}
+
+// requestMeta возвращает сведения о клиенте для журнала аудита.
+func requestMeta(c echo.Context) domain.RequestMeta {
+	return domain.RequestMeta{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
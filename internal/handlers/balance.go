@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -27,6 +28,7 @@ func (h *BalanceHandler) Register(e *echo.Echo) {
 	e.GET("/api/user/balance", h.GetBalance)
 	e.POST("/api/user/balance/withdraw", h.Withdraw)
 	e.GET("/api/user/withdrawals", h.GetWithdrawals)
+	e.GET("/api/user/balance/history", h.GetHistory)
 }
 
 // GetBalance возвращает текущий баланс пользователя.
@@ -37,7 +39,7 @@ func (h *BalanceHandler) GetBalance(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
 	}
 
-	balance, err := h.balanceService.GetBalance(userID)
+	balance, err := h.balanceService.GetBalance(c.Request().Context(), userID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 	}
@@ -62,7 +64,7 @@ func (h *BalanceHandler) Withdraw(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
 	}
 
-	err := h.balanceService.Withdraw(userID, &req)
+	err := h.balanceService.Withdraw(c.Request().Context(), userID, &req, requestMeta(c))
 	if err != nil {
 		if errors.Is(err, domain.ErrInvalidOrderNumber) {
 			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Неверный номер заказа")
@@ -77,6 +79,8 @@ func (h *BalanceHandler) Withdraw(c echo.Context) error {
 }
 
 // GetWithdrawals возвращает историю списаний пользователя.
+// Параметры status (active или reversed), since, until, sort, cursor и limit сужают список
+// и включают постраничный вывод; курсор следующей страницы приходит в заголовках X-Next-Cursor и Link.
 func (h *BalanceHandler) GetWithdrawals(c echo.Context) error {
 	userIDRaw := c.Get("user_id")
 	userID, ok := userIDRaw.(int)
@@ -84,14 +88,59 @@ func (h *BalanceHandler) GetWithdrawals(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
 	}
 
-	withdrawals, err := h.balanceService.GetWithdrawals(userID)
+	filter, err := parseWithdrawalFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверные параметры запроса")
+	}
+
+	page, err := h.balanceService.GetWithdrawals(c.Request().Context(), userID, filter)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 	}
 
-	if len(withdrawals) == 0 {
+	if len(page.Withdrawals) == 0 {
 		return c.NoContent(http.StatusNoContent)
 	}
 
-	return c.JSON(http.StatusOK, withdrawals)
+	setNextPageHeaders(c, page.NextCursor)
+	return c.JSON(http.StatusOK, page.Withdrawals)
+}
+
+// GetHistory возвращает историю операций по балансу пользователя.
+// Включает начисления, списания, корректировки службы поддержки и возвраты списаний.
+func (h *BalanceHandler) GetHistory(c echo.Context) error {
+	userIDRaw := c.Get("user_id")
+	userID, ok := userIDRaw.(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	operations, err := h.balanceService.GetHistory(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	if len(operations) == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	return c.JSON(http.StatusOK, operations)
+}
+
+// parseWithdrawalFilter разбирает параметры списка списаний.
+func parseWithdrawalFilter(c echo.Context) (domain.WithdrawalFilter, error) {
+	listFilter, err := parseListFilter(c)
+	if err != nil {
+		return domain.WithdrawalFilter{}, err
+	}
+	filter := domain.WithdrawalFilter{ListFilter: listFilter}
+
+	switch status := domain.WithdrawalStatus(c.QueryParam("status")); status {
+	case "", domain.WithdrawalStatusActive, domain.WithdrawalStatusReversed:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("invalid withdrawal status %q", status)
+	}
+
+	return filter, nil
 }
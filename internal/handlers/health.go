@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+// HealthHandler обрабатывает запросы оркестратора о живости и готовности сервиса.
+type HealthHandler struct {
+	healthService domain.HealthService
+}
+
+// NewHealthHandler создает новый экземпляр HealthHandler.
+func NewHealthHandler(healthService domain.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Liveness сообщает, что процесс жив.
+// @Summary Проверка живости.
+// @Tags status
+// @Produce json
+// @Success 200 {object} domain.HealthReport "Процесс работает"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.healthService.Liveness())
+}
+
+// Readiness сообщает, готов ли сервис принимать запросы.
+// @Summary Проверка готовности.
+// @Tags status
+// @Produce json
+// @Success 200 {object} domain.HealthReport "Все зависимости доступны"
+// @Failure 503 {object} domain.HealthReport "Зависимость недоступна или сервис останавливается"
+// @Router /readyz [get]
+// @Description Проверяет соединение с базой данных, актуальность миграций и доступность системы начислений.
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	report := h.healthService.Readiness(c.Request().Context())
+	if report.Status != domain.HealthUp {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/auth"
+)
+
+// jwksCacheControl разрешает клиентам кэшировать набор ключей, но подхватывать новые ключи после ротации.
+const jwksCacheControl = "public, max-age=300"
+
+// JWKSHandler публикует открытые ключи для проверки JWT токенов другими сервисами.
+type JWKSHandler struct {
+	keys *auth.KeySet
+}
+
+// NewJWKSHandler создает новый экземпляр JWKSHandler.
+func NewJWKSHandler(keys *auth.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS возвращает открытые ключи подписи.
+// @Summary Открытые ключи подписи JWT.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKS "Набор открытых ключей"
+// @Router /.well-known/jwks.json [get]
+// @Description Возвращает открытые ключи RS256 и EdDSA в формате JWKS; ключи HS256 не публикуются.
+func (h *JWKSHandler) GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", jwksCacheControl)
+	return c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -63,7 +64,7 @@ func (h *OrderHandler) Register(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса (тело запроса не может быть пустым)")
 	}
 
-	err = h.orderService.Register(userID, number)
+	err = h.orderService.Register(c.Request().Context(), userID, number, requestMeta(c))
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrOrderExists):
@@ -84,8 +85,17 @@ func (h *OrderHandler) Register(c echo.Context) error {
 // @Summary Получение списка заказов.
 // @Tags orders
 // @Produce json
+// @Param status query string false "Статусы заказов через запятую: NEW, PROCESSING, INVALID, PROCESSED"
+// @Param since query string false "Начало периода загрузки (RFC 3339)"
+// @Param until query string false "Конец периода загрузки (RFC 3339, не включается)"
+// @Param sort query string false "Порядок по времени загрузки: desc (по умолчанию) или asc"
+// @Param cursor query string false "Курсор из заголовка X-Next-Cursor предыдущей страницы"
+// @Param limit query int false "Размер страницы (не более 1000; без limit и cursor - весь список)"
 // @Success 200 {array} domain.Order "Список заказов"
+// @Header 200 {string} X-Next-Cursor "Курсор следующей страницы, если она есть"
+// @Header 200 {string} Link "Ссылка на следующую страницу (rel=next), если она есть"
 // @Success 204 "Нет данных для ответа"
+// @Failure 400 "Неверные параметры запроса"
 // @Failure 401 "Пользователь не аутентифицирован"
 // @Failure 500 "Внутренняя ошибка сервера"
 // @Router /api/user/orders [get]
@@ -98,7 +108,12 @@ func (h *OrderHandler) GetOrders(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
 	}
 
-	orders, err := h.orderService.GetOrders(userID)
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверные параметры запроса")
+	}
+
+	page, err := h.orderService.GetOrders(c.Request().Context(), userID, filter)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return c.NoContent(http.StatusNoContent)
@@ -106,9 +121,64 @@ func (h *OrderHandler) GetOrders(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 	}
 
-	if len(orders) == 0 {
+	if len(page.Orders) == 0 {
 		return c.NoContent(http.StatusNoContent)
 	}
 
-	return c.JSON(http.StatusOK, orders)
+	setNextPageHeaders(c, page.NextCursor)
+	return c.JSON(http.StatusOK, page.Orders)
+}
+
+// GetOrder возвращает заказ пользователя с историей обработки.
+// @Summary Получение заказа с историей обработки.
+// @Tags orders
+// @Produce json
+// @Param number path string true "Номер заказа"
+// @Success 200 {object} domain.OrderDetails "Заказ, начисление и история смены статусов"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 404 "Заказ не найден или принадлежит другому пользователю"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/user/orders/{number} [get]
+// @Description Возвращает заказ, сумму начисления, количество запросов в систему начислений
+// и историю смены статусов.
+func (h *OrderHandler) GetOrder(c echo.Context) error {
+	userIDRaw := c.Get("user_id")
+	userID, ok := userIDRaw.(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	order, err := h.orderService.GetOrder(c.Request().Context(), userID, c.Param("number"))
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Заказ не найден")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+// parseOrderFilter разбирает параметры списка заказов.
+func parseOrderFilter(c echo.Context) (domain.OrderFilter, error) {
+	listFilter, err := parseListFilter(c)
+	if err != nil {
+		return domain.OrderFilter{}, err
+	}
+	filter := domain.OrderFilter{ListFilter: listFilter}
+
+	for _, value := range queryValues(c, "status") {
+		status := domain.OrderStatus(strings.ToUpper(value))
+		switch status {
+		case domain.OrderStatusNew,
+			domain.OrderStatusProcessing,
+			domain.OrderStatusInvalid,
+			domain.OrderStatusProcessed:
+			filter.Statuses = append(filter.Statuses, status)
+		default:
+			return filter, fmt.Errorf("invalid order status %q", value)
+		}
+	}
+
+	return filter, nil
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	// nextCursorHeader заголовок с курсором следующей страницы списка.
+	nextCursorHeader = "X-Next-Cursor"
+	// linkHeader заголовок RFC 8288 со ссылкой на следующую страницу.
+	linkHeader = "Link"
+)
+
+// parseListFilter разбирает общие параметры списков: limit, cursor, sort, since и until.
+func parseListFilter(c echo.Context) (domain.ListFilter, error) {
+	var filter domain.ListFilter
+
+	limit, err := queryInt(c, "limit")
+	if err != nil || limit < 0 {
+		return filter, fmt.Errorf("invalid limit %q", c.QueryParam("limit"))
+	}
+	filter.Limit = limit
+
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := domain.ParsePageCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = cursor
+	}
+
+	switch sort := domain.SortOrder(c.QueryParam("sort")); sort {
+	case "":
+		filter.Sort = domain.SortNewestFirst
+	case domain.SortNewestFirst, domain.SortOldestFirst:
+		filter.Sort = sort
+	default:
+		return filter, fmt.Errorf("invalid sort %q", sort)
+	}
+
+	// Курсор одного порядка в другом указывает не на ту позицию, поэтому смешивать их нельзя
+	if filter.After != nil && filter.After.Sort != filter.Sort {
+		return filter, fmt.Errorf("%w: cursor is for sort %q, requested %q",
+			domain.ErrInvalidCursor, filter.After.Sort, filter.Sort)
+	}
+
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.QueryParam(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, err
+			}
+			*target = &parsed
+		}
+	}
+
+	return filter, nil
+}
+
+// queryValues возвращает значения параметра, переданного несколько раз или через запятую.
+func queryValues(c echo.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryParams()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// setNextPageHeaders сообщает клиенту курсор следующей страницы в заголовках X-Next-Cursor и Link.
+// Ссылка повторяет текущий запрос со всеми фильтрами, меняется только курсор.
+func setNextPageHeaders(c echo.Context, cursor string) {
+	if cursor == "" {
+		return
+	}
+
+	next := *c.Request().URL
+	query := next.Query()
+	query.Set("cursor", cursor)
+	next.RawQuery = query.Encode()
+
+	c.Response().Header().Set(nextCursorHeader, cursor)
+	c.Response().Header().Set(linkHeader, fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
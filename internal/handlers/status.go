@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gophermart/internal/domain"
+)
+
+// StatusHandler обрабатывает служебные запросы о состоянии внешних зависимостей.
+type StatusHandler struct {
+	accrualStatus domain.AccrualStatusProvider
+}
+
+// NewStatusHandler создает новый экземпляр StatusHandler.
+func NewStatusHandler(accrualStatus domain.AccrualStatusProvider) *StatusHandler {
+	return &StatusHandler{accrualStatus: accrualStatus}
+}
+
+// GetAccrualStatus возвращает состояние автоматического выключателя системы начислений.
+// @Summary Состояние клиента системы начислений.
+// @Tags status
+// @Produce json
+// @Success 200 {object} domain.CircuitStatus "Состояние автоматического выключателя"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 403 "Недостаточно прав"
+// @Router /api/admin/status/accrual [get]
+// @Description Возвращает состояние цепи (closed, open, half-open), число ошибок подряд и последнюю ошибку.
+func (h *StatusHandler) GetAccrualStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.accrualStatus.CircuitStatus())
+}
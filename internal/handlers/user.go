@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
-	"errors"
 	"gophermart/internal/domain"
 	"gophermart/internal/service"
 )
@@ -27,7 +30,7 @@ func NewUserHandler(userService domain.UserService) *UserHandler {
 // @Produce json
 // @Param request body domain.RegisterRequest true "Учетные данные для регистрации"
 // @Success 200 {object} domain.AuthToken "Пользователь успешно зарегистрирован"
-// @Failure 400 "Неверный формат запроса"
+// @Failure 400 "Неверный формат запроса или пароль не соответствует требованиям"
 // @Failure 409 "Логин уже занят"
 // @Failure 500 "Внутренняя ошибка сервера"
 // @Router /api/user/register [post]
@@ -42,12 +45,17 @@ func (h *UserHandler) Register(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
 	}
 
-	token, err := h.userService.Register(req.Login, req.Password)
+	token, err := h.userService.Register(c.Request().Context(), req.Login, req.Password, requestMeta(c))
 	if err != nil {
-		if errors.Is(err, service.ErrUserExists) {
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.Is(err, service.ErrUserExists):
 			return echo.NewHTTPError(http.StatusConflict, "Пользователь уже существует")
+		case errors.As(err, &policyErr):
+			return echo.NewHTTPError(http.StatusBadRequest, policyErr.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 	}
 
 	// Устанавливаем токен в заголовок Authorization
@@ -70,9 +78,12 @@ type LoginRequest struct {
 // @Success 200 {object} domain.AuthToken "Пользователь успешно аутентифицирован"
 // @Failure 400 "Неверный формат запроса"
 // @Failure 401 "Неверная пара логин/пароль"
+// @Failure 403 "Учетная запись заблокирована"
+// @Failure 429 "Вход временно заблокирован после серии неудачных попыток"
 // @Failure 500 "Внутренняя ошибка сервера"
 // @Router /api/user/login [post]
 // @Description Аутентифицирует пользователя по логину и паролю.
+// @Description После серии неудачных попыток вход блокируется, время до разблокировки передается в Retry-After.
 func (h *UserHandler) Authenticate(c echo.Context) error {
 	var req LoginRequest
 	if err := c.Bind(&req); err != nil {
@@ -83,10 +94,58 @@ func (h *UserHandler) Authenticate(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
 	}
 
-	token, err := h.userService.Authenticate(req.Login, req.Password)
+	token, err := h.userService.Authenticate(c.Request().Context(), req.Login, req.Password, requestMeta(c))
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidLogin) {
+		var lockedErr *service.LoginLockedError
+		switch {
+		case errors.Is(err, service.ErrInvalidLogin):
 			return echo.NewHTTPError(http.StatusUnauthorized, "Неверный логин или пароль")
+		case errors.Is(err, service.ErrUserBlocked):
+			return echo.NewHTTPError(http.StatusForbidden, "Учетная запись заблокирована")
+		case errors.As(err, &lockedErr):
+			retryAfter := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+			c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			return echo.NewHTTPError(
+				http.StatusTooManyRequests,
+				fmt.Sprintf("Слишком много неудачных попыток входа, повторите через %d с", retryAfter),
+			)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		}
+	}
+
+	// Устанавливаем токен в заголовок Authorization
+	c.Response().Header().Set("Authorization", "Bearer "+token.Token)
+	return c.JSON(http.StatusOK, token)
+}
+
+// Refresh обрабатывает обновление пары токенов.
+// @Summary Обновление токенов.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} domain.AuthToken "Выдана новая пара токенов"
+// @Failure 400 "Неверный формат запроса"
+// @Failure 401 "Refresh-токен недействителен, истек или уже использован"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/user/token/refresh [post]
+// @Description Обменивает refresh-токен на новую пару токенов. Каждый refresh-токен действует один раз,
+// @Description повторное предъявление завершает сессию.
+func (h *UserHandler) Refresh(c echo.Context) error {
+	var req domain.RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
+	}
+
+	token, err := h.userService.Refresh(c.Request().Context(), req.RefreshToken, requestMeta(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Недействительный refresh-токен")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
 	}
@@ -95,3 +154,111 @@ func (h *UserHandler) Authenticate(c echo.Context) error {
 	c.Response().Header().Set("Authorization", "Bearer "+token.Token)
 	return c.JSON(http.StatusOK, token)
 }
+
+// Logout обрабатывает завершение текущей сессии.
+// @Summary Выход из текущей сессии.
+// @Tags auth
+// @Success 204 "Сессия завершена"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/user/logout [post]
+// @Description Отзывает сессию, к которой относится access-токен, вместе с ее refresh-токенами.
+func (h *UserHandler) Logout(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	sessionID, ok := c.Get("session_id").(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid session_id in context")
+	}
+
+	if err := h.userService.Logout(c.Request().Context(), userID, sessionID, requestMeta(c)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// LogoutAll обрабатывает завершение всех сессий пользователя.
+// @Summary Выход из всех сессий.
+// @Tags auth
+// @Success 204 "Все сессии завершены"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/user/logout-all [post]
+// @Description Отзывает все сессии пользователя на всех устройствах.
+func (h *UserHandler) LogoutAll(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	if err := h.userService.LogoutAll(c.Request().Context(), userID, requestMeta(c)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ChangePassword обрабатывает смену пароля.
+// @Summary Смена пароля.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.ChangePasswordRequest true "Текущий и новый пароль"
+// @Success 200 {object} domain.AuthToken "Пароль изменен, выдана новая пара токенов"
+// @Failure 400 "Неверный формат запроса или пароль не соответствует требованиям"
+// @Failure 401 "Пользователь не аутентифицирован"
+// @Failure 403 "Неверный текущий пароль"
+// @Failure 429 "Смена пароля временно заблокирована после серии неудачных попыток"
+// @Failure 500 "Внутренняя ошибка сервера"
+// @Router /api/user/password [put]
+// @Description Меняет пароль и завершает все сессии пользователя; клиент продолжает работу с новой парой токенов.
+func (h *UserHandler) ChangePassword(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "invalid user_id in context")
+	}
+
+	var req domain.ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Неверный формат запроса")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ошибка валидации")
+	}
+
+	token, err := h.userService.ChangePassword(
+		c.Request().Context(),
+		userID,
+		req.OldPassword,
+		req.NewPassword,
+		requestMeta(c),
+	)
+	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		var lockedErr *service.LoginLockedError
+		switch {
+		case errors.Is(err, service.ErrInvalidPassword):
+			return echo.NewHTTPError(http.StatusForbidden, "Неверный текущий пароль")
+		case errors.As(err, &policyErr):
+			return echo.NewHTTPError(http.StatusBadRequest, policyErr.Error())
+		case errors.As(err, &lockedErr):
+			retryAfter := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+			c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			return echo.NewHTTPError(
+				http.StatusTooManyRequests,
+				fmt.Sprintf("Слишком много неудачных попыток, повторите через %d с", retryAfter),
+			)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		}
+	}
+
+	// Устанавливаем токен в заголовок Authorization
+	c.Response().Header().Set("Authorization", "Bearer "+token.Token)
+	return c.JSON(http.StatusOK, token)
+}
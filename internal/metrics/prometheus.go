@@ -0,0 +1,219 @@
+// Package metrics реализует интерфейсы метрик из domain поверх Prometheus.
+package metrics
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gophermart/internal/domain"
+)
+
+const namespace = "gophermart"
+
+// Prometheus реализует domain.HTTPMetrics, domain.AccrualMetrics, domain.WorkerMetrics
+// и domain.BalanceMetrics. Метрики регистрируются в собственном реестре, а не в глобальном.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	accrualRequests   *prometheus.CounterVec
+	accrualDuration   *prometheus.HistogramVec
+	accrualRetryAfter prometheus.Histogram
+
+	ordersFinalized *prometheus.CounterVec
+	processingLag   *prometheus.HistogramVec
+
+	accrualCredited     prometheus.Counter
+	withdrawals         prometheus.Counter
+	withdrawnAmount     prometheus.Counter
+	withdrawalsRejected *prometheus.CounterVec
+	adjustments         *prometheus.CounterVec
+	adjustedAmount      *prometheus.CounterVec
+}
+
+// New создает реестр метрик приложения вместе со стандартными метриками процесса и среды Go.
+func New() *Prometheus {
+	m := &Prometheus{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Обработанные HTTP-запросы по маршруту и коду ответа.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Длительность обработки HTTP-запросов.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		accrualRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "requests_total",
+			Help:      "Запросы к системе начислений по исходу.",
+		}, []string{"outcome"}),
+		accrualDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "request_duration_seconds",
+			Help:      "Длительность запросов к системе начислений.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		accrualRetryAfter: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "retry_after_seconds",
+			Help:      "Паузы, назначенные системой начислений в заголовке Retry-After.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300},
+		}),
+
+		ordersFinalized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "worker",
+			Name:      "orders_finalized_total",
+			Help:      "Заказы, получившие окончательный статус.",
+		}, []string{"status"}),
+		processingLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "worker",
+			Name:      "processing_lag_seconds",
+			Help:      "Время от загрузки заказа до окончательного статуса.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 300, 900, 3600, 6 * 3600, 24 * 3600},
+		}, []string{"status"}),
+
+		accrualCredited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "accrued_total",
+			Help:      "Баллы, начисленные за обработанные заказы.",
+		}),
+		withdrawals: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "withdrawals_total",
+			Help:      "Выполненные списания.",
+		}),
+		withdrawnAmount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "withdrawn_total",
+			Help:      "Списанные баллы.",
+		}),
+		withdrawalsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "withdrawals_rejected_total",
+			Help:      "Отклоненные запросы на списание по причине.",
+		}, []string{"reason"}),
+		adjustments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "adjustments_total",
+			Help:      "Корректировки баланса службой поддержки.",
+		}, []string{"kind", "direction"}),
+		adjustedAmount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "balance",
+			Name:      "adjusted_total",
+			Help:      "Баллы, зачисленные или списанные корректировками.",
+		}, []string{"kind", "direction"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		m.httpRequests,
+		m.httpDuration,
+		m.accrualRequests,
+		m.accrualDuration,
+		m.accrualRetryAfter,
+		m.ordersFinalized,
+		m.processingLag,
+		m.accrualCredited,
+		m.withdrawals,
+		m.withdrawnAmount,
+		m.withdrawalsRejected,
+		m.adjustments,
+		m.adjustedAmount,
+	)
+
+	return m
+}
+
+// RegisterDB добавляет метрики пула соединений с базой данных.
+func (m *Prometheus) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterOrderQueue добавляет метрики очереди заказов; состояние запрашивается при каждом сборе.
+func (m *Prometheus) RegisterOrderQueue(source domain.OrderQueueSource, logger *slog.Logger) {
+	m.registry.MustRegister(newQueueCollector(source, logger))
+}
+
+// Handler возвращает обработчик, отдающий метрики в формате Prometheus.
+func (m *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTPRequest учитывает обработанный HTTP-запрос.
+func (m *Prometheus) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveAccrualRequest учитывает запрос к системе начислений.
+func (m *Prometheus) ObserveAccrualRequest(outcome string, duration time.Duration) {
+	m.accrualRequests.WithLabelValues(outcome).Inc()
+	m.accrualDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObserveAccrualRetryAfter учитывает паузу из заголовка Retry-After.
+func (m *Prometheus) ObserveAccrualRetryAfter(wait time.Duration) {
+	m.accrualRetryAfter.Observe(wait.Seconds())
+}
+
+// ObserveOrderFinalized учитывает заказ, получивший окончательный статус.
+func (m *Prometheus) ObserveOrderFinalized(status domain.OrderStatus, lag time.Duration) {
+	m.ordersFinalized.WithLabelValues(string(status)).Inc()
+	m.processingLag.WithLabelValues(string(status)).Observe(lag.Seconds())
+}
+
+// ObserveAccrualCredited учитывает начисленные баллы.
+func (m *Prometheus) ObserveAccrualCredited(amount domain.Money) {
+	m.accrualCredited.Add(amount.Float64())
+}
+
+// ObserveWithdrawal учитывает списание.
+func (m *Prometheus) ObserveWithdrawal(amount domain.Money) {
+	m.withdrawals.Inc()
+	m.withdrawnAmount.Add(amount.Float64())
+}
+
+// ObserveWithdrawalRejected учитывает отклоненный запрос на списание.
+func (m *Prometheus) ObserveWithdrawalRejected(reason string) {
+	m.withdrawalsRejected.WithLabelValues(reason).Inc()
+}
+
+// ObserveAdjustment учитывает корректировку баланса.
+func (m *Prometheus) ObserveAdjustment(
+	kind domain.LedgerReferenceType,
+	direction domain.LedgerDirection,
+	amount domain.Money,
+) {
+	m.adjustments.WithLabelValues(string(kind), string(direction)).Inc()
+	m.adjustedAmount.WithLabelValues(string(kind), string(direction)).Add(amount.Float64())
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"gophermart/internal/domain"
+)
+
+// queueStatsTimeout ограничивает время запроса состояния очереди, чтобы медленная база не задерживала сбор метрик.
+const queueStatsTimeout = 5 * time.Second
+
+// queueCollector собирает состояние очереди заказов при каждом запросе метрик.
+type queueCollector struct {
+	source    domain.OrderQueueSource
+	logger    *slog.Logger
+	depth     *prometheus.Desc
+	oldestAge *prometheus.Desc
+}
+
+// newQueueCollector создает новый экземпляр queueCollector.
+func newQueueCollector(source domain.OrderQueueSource, logger *slog.Logger) *queueCollector {
+	return &queueCollector{
+		source: source,
+		logger: logger.With(
+			"package", "metrics",
+			"component", "queueCollector",
+		),
+		depth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "worker", "orders"),
+			"Количество заказов по статусам.",
+			[]string{"status"}, nil,
+		),
+		oldestAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "worker", "oldest_pending_order_age_seconds"),
+			"Возраст самого старого заказа без окончательного статуса.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe реализует интерфейс prometheus.Collector.
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.oldestAge
+}
+
+// Collect реализует интерфейс prometheus.Collector.
+// Если состояние очереди получить не удалось, метрики очереди пропускаются, а остальные отдаются как обычно.
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueStatsTimeout)
+	defer cancel()
+
+	stats, err := c.source.QueueStats(ctx)
+	if err != nil {
+		c.logger.Error("не удалось получить состояние очереди заказов", "error", err)
+		return
+	}
+
+	// Статусы очереди отдаются всегда, чтобы пустая очередь давала нули, а не пропуски рядов
+	for _, status := range []domain.OrderStatus{
+		domain.OrderStatusNew,
+		domain.OrderStatusProcessing,
+		domain.OrderStatusProcessed,
+		domain.OrderStatusInvalid,
+	} {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(stats.Counts[status]), string(status))
+	}
+	ch <- prometheus.MustNewConstMetric(c.oldestAge, prometheus.GaugeValue, stats.OldestPendingAge.Seconds())
+}
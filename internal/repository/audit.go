@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"gophermart/internal/domain"
+)
+
+// AuditRepo реализует интерфейс domain.AuditRepository.
+type AuditRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewAuditRepo создает новый экземпляр AuditRepo.
+func NewAuditRepo(db *sqlx.DB, logger *slog.Logger) *AuditRepo {
+	return &AuditRepo{
+		db: db,
+		logger: logger.With(
+			"package", "repository",
+			"component", "AuditRepo",
+		),
+	}
+}
+
+// Append добавляет событие в журнал. Идентификатор, время и хеши цепочки назначает триггер.
+func (r *AuditRepo) Append(event *domain.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events
+			(actor_type, actor_id, action, target_type, target_id, ip, user_agent, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, prev_hash, hash`
+
+	if err := r.db.QueryRowx(
+		query,
+		event.ActorType,
+		event.ActorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IP,
+		event.UserAgent,
+		event.Payload,
+	).Scan(&event.ID, &event.CreatedAt, &event.PrevHash, &event.Hash); err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+
+	return nil
+}
+
+// Find возвращает события по фильтру, от новых к старым.
+func (r *AuditRepo) Find(filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		addCondition("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.IP != "" {
+		addCondition("ip = $%d", filter.IP)
+	}
+	if filter.Since != nil {
+		addCondition("created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		addCondition("created_at < $%d", *filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT * FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	var events []domain.AuditEvent
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to find audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// VerifyChain пересчитывает хеш каждой записи и сверяет ссылку на предыдущую запись.
+// Удаление или изменение строки в обход триггеров нарушает цепочку начиная с этой строки.
+func (r *AuditRepo) VerifyChain(limit int) ([]domain.AuditChainViolation, error) {
+	logger := r.logger.With("method", "VerifyChain")
+
+	query := `
+		SELECT id, reason
+		FROM (
+			SELECT e.id,
+				CASE
+					WHEN e.prev_hash <> COALESCE(LAG(e.hash) OVER (ORDER BY e.id), repeat('0', 64))
+						THEN 'prev_hash_mismatch'
+					WHEN e.hash <> audit_event_hash(e)
+						THEN 'hash_mismatch'
+				END AS reason
+			FROM audit_events e
+		) checked
+		WHERE reason IS NOT NULL
+		ORDER BY id
+		LIMIT $1`
+
+	var violations []domain.AuditChainViolation
+	if err := r.db.Select(&violations, query, limit); err != nil {
+		logger.Error("ошибка проверки цепочки журнала аудита", "error", err)
+		return nil, fmt.Errorf("failed to verify audit chain: %w", err)
+	}
+
+	return violations, nil
+}
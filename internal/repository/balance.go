@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 
 	"gophermart/internal/domain"
 )
@@ -26,64 +31,265 @@ func NewBalanceRepo(db *sqlx.DB, logger *slog.Logger) *BalanceRepo {
 }
 
 // GetBalance возвращает текущий баланс пользователя.
-func (r *BalanceRepo) GetBalance(userID int) (*domain.Balance, error) {
-	var balance domain.Balance
+func (r *BalanceRepo) GetBalance(ctx context.Context, userID int) (_ *domain.Balance, err error) {
+	ctx, span := startSpan(ctx, "BalanceRepo.GetBalance", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	return getBalance(ctx, r.db, userID)
+}
+
+// WithBalanceLock выполняет fn в транзакции под блокировкой строки баланса пользователя.
+// Блокировка SELECT ... FOR UPDATE сериализует все операции с балансом одного пользователя,
+// поэтому проверка баланса и списание внутри fn не могут пересечься с параллельным запросом.
+func (r *BalanceRepo) WithBalanceLock(
+	ctx context.Context,
+	userID int,
+	fn func(tx domain.BalanceTx) error,
+) (err error) {
+	ctx, span := startSpan(ctx, "BalanceRepo.WithBalanceLock", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	logger := r.logger.With("method", "WithBalanceLock", "user_id", userID)
+
+	tx, beginErr := r.db.BeginTxx(ctx, nil)
+	if beginErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() {
+		// После успешного Commit откат вернет sql.ErrTxDone, который можно игнорировать
+		_ = tx.Rollback()
+	}()
+
+	if lockErr := lockBalance(ctx, tx, userID); lockErr != nil {
+		logger.Error("не удалось заблокировать баланс пользователя", "error", lockErr)
+		return fmt.Errorf("failed to lock user balance: %w", lockErr)
+	}
+
+	if fnErr := fn(&balanceTx{tx: tx}); fnErr != nil {
+		return fnErr
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("failed to commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+// GetWithdrawals возвращает историю списаний пользователя по фильтру.
+func (r *BalanceRepo) GetWithdrawals(
+	ctx context.Context,
+	userID int,
+	filter domain.WithdrawalFilter,
+) (_ []domain.Withdrawal, err error) {
+	ctx, span := startSpan(ctx, "BalanceRepo.GetWithdrawals", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	var q listQuery
+	q.where("w.user_id = $%d", userID)
+	switch filter.Status {
+	case domain.WithdrawalStatusActive:
+		q.where("a.id IS NULL")
+	case domain.WithdrawalStatusReversed:
+		q.where("a.id IS NOT NULL")
+	}
+	query := q.build(`
+		SELECT w.id, w.order_number, w.amount_kop, w.processed_at, a.created_at AS reversed_at
+		FROM withdrawals w
+		LEFT JOIN balance_adjustments a ON a.withdrawal_id = w.id`, filter.ListFilter, "w.processed_at", "w.id")
+
+	var withdrawals []domain.Withdrawal
+	if err = r.db.SelectContext(ctx, &withdrawals, query, q.args...); err != nil {
+		return nil, err
+	}
+
+	return withdrawals, nil
+}
+
+// GetAdjustments возвращает корректировки баланса пользователя, начиная с последней.
+func (r *BalanceRepo) GetAdjustments(ctx context.Context, userID int) (_ []domain.Adjustment, err error) {
+	ctx, span := startSpan(ctx, "BalanceRepo.GetAdjustments", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	var adjustments []domain.Adjustment
+	query := `
+		SELECT *
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	if err = r.db.SelectContext(ctx, &adjustments, query, userID); err != nil {
+		return nil, err
+	}
+
+	return adjustments, nil
+}
 
-	// Получаем сумму всех начислений (сразу в рублях, null значения заменяются на 0)
-	err := r.db.Get(&balance.Current, `
-		SELECT COALESCE(SUM(accrual), 0)::float / 100.0
-		FROM orders 
-		WHERE user_id = $1 AND status = 'PROCESSED'`, userID)
+// GetHistory возвращает все операции по балансу пользователя, начиная с последней.
+// История строится по журналу проводок, поэтому в нее попадают начисления, списания,
+// корректировки и возвраты списаний.
+func (r *BalanceRepo) GetHistory(ctx context.Context, userID int) (_ []domain.BalanceOperation, err error) {
+	ctx, span := startSpan(ctx, "BalanceRepo.GetHistory", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	var operations []domain.BalanceOperation
+	query := `
+		SELECT l.reference_type,
+			CASE l.direction WHEN 'CREDIT' THEN l.amount_kop ELSE -l.amount_kop END AS amount_kop,
+			COALESCE(o.number, w.order_number, '') AS order_number,
+			COALESCE(a.reason, '') AS reason,
+			l.created_at
+		FROM ledger_entries l
+		LEFT JOIN orders o
+			ON l.reference_type = 'order' AND o.id = l.reference_id
+		LEFT JOIN balance_adjustments a
+			ON l.reference_type IN ('adjustment', 'withdrawal_reversal') AND a.id = l.reference_id
+		LEFT JOIN withdrawals w
+			ON (l.reference_type = 'withdrawal' AND w.id = l.reference_id)
+			OR (l.reference_type = 'withdrawal_reversal' AND w.id = a.withdrawal_id)
+		WHERE l.user_id = $1
+		ORDER BY l.created_at DESC, l.id DESC`
+
+	if err = r.db.SelectContext(ctx, &operations, query, userID); err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+// balanceTx реализует интерфейс domain.BalanceTx поверх открытой транзакции.
+type balanceTx struct {
+	tx *sqlx.Tx
+}
+
+// GetBalance возвращает баланс пользователя в рамках транзакции.
+func (t *balanceTx) GetBalance(ctx context.Context, userID int) (*domain.Balance, error) {
+	return getBalance(ctx, t.tx, userID)
+}
+
+// CreateWithdrawal создает запись о списании в рамках транзакции.
+func (t *balanceTx) CreateWithdrawal(ctx context.Context, userID int, withdrawal *domain.Withdrawal) error {
+	return createWithdrawal(ctx, t.tx, userID, withdrawal)
+}
+
+// GetWithdrawal возвращает списание пользователя в рамках транзакции.
+func (t *balanceTx) GetWithdrawal(ctx context.Context, userID, withdrawalID int) (*domain.Withdrawal, error) {
+	var withdrawal domain.Withdrawal
+	err := t.tx.GetContext(ctx, &withdrawal, `
+		SELECT w.id, w.order_number, w.amount_kop, w.processed_at, a.created_at AS reversed_at
+		FROM withdrawals w
+		LEFT JOIN balance_adjustments a ON a.withdrawal_id = w.id
+		WHERE w.id = $1 AND w.user_id = $2`, withdrawalID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrWithdrawalNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Получаем сумму всех списаний
-	err = r.db.Get(&balance.Withdrawn, `
-		SELECT COALESCE(SUM(amount_kop), 0)::float / 100.0
-		FROM withdrawals 
+	return &withdrawal, nil
+}
+
+// CreateAdjustment создает корректировку баланса в рамках транзакции.
+func (t *balanceTx) CreateAdjustment(ctx context.Context, adjustment *domain.Adjustment) error {
+	return createAdjustment(ctx, t.tx, adjustment)
+}
+
+// lockBalance создает при необходимости строку кэша баланса и блокирует ее до конца транзакции.
+func lockBalance(ctx context.Context, tx *sqlx.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO balances (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING`, userID); err != nil {
+		return err
+	}
+
+	var lockedID int
+	return tx.GetContext(ctx, &lockedID, `SELECT user_id FROM balances WHERE user_id = $1 FOR UPDATE`, userID)
+}
+
+// getBalance читает баланс пользователя из кэша balances через переданное соединение или транзакцию.
+func getBalance(ctx context.Context, q sqlx.QueryerContext, userID int) (*domain.Balance, error) {
+	var balance domain.Balance
+
+	// Пользователь без операций не имеет строки в balances, поэтому баланс нулевой
+	err := sqlx.GetContext(ctx, q, &balance, `
+		SELECT
+			COALESCE(SUM(current_kop), 0)::bigint AS current,
+			COALESCE(SUM(withdrawn_kop), 0)::bigint AS withdrawn
+		FROM balances
 		WHERE user_id = $1`, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Вычитаем списания из начислений
-	balance.Current -= balance.Withdrawn
 	return &balance, nil
 }
 
-// CreateWithdrawal создает новую запись о списании средств.
-func (r *BalanceRepo) CreateWithdrawal(userID int, withdrawal *domain.Withdrawal) error {
+// createWithdrawal добавляет запись о списании и соответствующую проводку в журнал.
+// Должна вызываться внутри транзакции, чтобы списание и проводка были атомарны.
+func createWithdrawal(ctx context.Context, tx *sqlx.Tx, userID int, withdrawal *domain.Withdrawal) error {
 	query := `
 		INSERT INTO withdrawals (user_id, order_number, amount_kop)
 		VALUES ($1, $2, $3)
-		RETURNING processed_at`
+		RETURNING id, processed_at`
 
-	return r.db.QueryRow(
+	if err := tx.QueryRowxContext(
+		ctx,
 		query,
 		userID,
 		withdrawal.Order,
-		withdrawal.AmountKop,
-	).Scan(&withdrawal.ProcessedAt)
+		withdrawal.Sum,
+	).Scan(&withdrawal.ID, &withdrawal.ProcessedAt); err != nil {
+		return err
+	}
+
+	return postLedger(ctx, tx, ledgerPosting{
+		UserID:         userID,
+		Direction:      domain.LedgerDebit,
+		Amount:         withdrawal.Sum,
+		CounterAccount: domain.LedgerAccountWithdrawal,
+		ReferenceType:  domain.LedgerReferenceWithdrawal,
+		ReferenceID:    withdrawal.ID,
+		WithdrawnDelta: withdrawal.Sum,
+	})
 }
 
-// GetWithdrawals возвращает историю списаний пользователя.
-func (r *BalanceRepo) GetWithdrawals(userID int) ([]domain.Withdrawal, error) {
-	var withdrawals []domain.Withdrawal
+// createAdjustment добавляет корректировку баланса и соответствующую проводку в журнал.
+// Возврат списания проводится против счета списаний и уменьшает сумму списаний в кэше баланса.
+func createAdjustment(ctx context.Context, tx *sqlx.Tx, adjustment *domain.Adjustment) error {
 	query := `
-		SELECT order_number, amount_kop, processed_at
-		FROM withdrawals 
-		WHERE user_id = $1 
-		ORDER BY processed_at DESC`
+		INSERT INTO balance_adjustments
+			(user_id, operator_id, kind, direction, amount_kop, withdrawal_id, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
 
-	if err := r.db.Select(&withdrawals, query, userID); err != nil {
-		return nil, err
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		adjustment.UserID,
+		adjustment.OperatorID,
+		adjustment.Kind,
+		adjustment.Direction,
+		adjustment.Amount,
+		adjustment.WithdrawalID,
+		adjustment.Reason,
+	).Scan(&adjustment.ID, &adjustment.CreatedAt); err != nil {
+		return err
 	}
 
-	// Конвертируем копейки в рубли
-	for i := range withdrawals {
-		withdrawals[i].Sum = float64(withdrawals[i].AmountKop) / domain.KopPerRuble
+	posting := ledgerPosting{
+		UserID:         adjustment.UserID,
+		Direction:      adjustment.Direction,
+		Amount:         adjustment.Amount,
+		CounterAccount: domain.LedgerAccountAdjustment,
+		ReferenceType:  adjustment.Kind,
+		ReferenceID:    adjustment.ID,
+	}
+	if adjustment.Kind == domain.LedgerReferenceWithdrawalReversal {
+		posting.CounterAccount = domain.LedgerAccountWithdrawal
+		posting.WithdrawnDelta = -adjustment.Amount
 	}
 
-	return withdrawals, nil
+	return postLedger(ctx, tx, posting)
 }
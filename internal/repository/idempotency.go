@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"gophermart/internal/domain"
+)
+
+// IdempotencyRepo реализует интерфейс domain.IdempotencyRepository.
+type IdempotencyRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewIdempotencyRepo создает новый экземпляр IdempotencyRepo.
+func NewIdempotencyRepo(db *sqlx.DB, logger *slog.Logger) *IdempotencyRepo {
+	return &IdempotencyRepo{
+		db: db,
+		logger: logger.With(
+			"package", "repository",
+			"component", "IdempotencyRepo",
+		),
+	}
+}
+
+// Reserve занимает ключ для нового запроса или возвращает уже существующую запись.
+func (r *IdempotencyRepo) Reserve(
+	ctx context.Context,
+	record *domain.IdempotencyRecord,
+) (*domain.IdempotencyRecord, bool, error) {
+	logger := r.logger.With("method", "Reserve", "user_id", record.UserID)
+
+	// Истекшие ключи пользователя удаляем, чтобы их можно было использовать повторно
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND expires_at <= CURRENT_TIMESTAMP`, record.UserID); err != nil {
+		return nil, false, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	// Запись без ответа с истекшей арендой осталась от запроса, который не завершился
+	// (паника или падение процесса): повтор занимает ее, а не получает 409 до истечения ключа
+	var takenOver bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until,
+			created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_keys.status_code IS NULL
+			AND idempotency_keys.locked_until <= CURRENT_TIMESTAMP
+		RETURNING created_at, xmax <> 0`,
+		record.UserID, record.Key, record.RequestHash, record.ExpiresAt, record.LockedUntil,
+	).Scan(&record.CreatedAt, &takenOver)
+	if err == nil {
+		if takenOver {
+			logger.Warn("занят ключ идемпотентности незавершенного запроса", "key", record.Key)
+		}
+		return record, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	// Ключ уже занят: возвращаем сохраненную запись
+	var existing domain.IdempotencyRecord
+	if getErr := r.db.GetContext(ctx, &existing, `
+		SELECT user_id, key, request_hash, status_code, content_type, response_body, created_at, expires_at,
+			locked_until
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2`, record.UserID, record.Key); getErr != nil {
+		return nil, false, fmt.Errorf("failed to load idempotency key: %w", getErr)
+	}
+
+	logger.Debug("повторный запрос с ключом идемпотентности", "завершен", existing.Completed())
+	return &existing, false, nil
+}
+
+// Extend продлевает аренду ключа, пока запрос выполняется. Завершенные записи не меняются.
+func (r *IdempotencyRepo) Extend(ctx context.Context, userID int, key string, lockedUntil time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET locked_until = $3
+		WHERE user_id = $1 AND key = $2 AND status_code IS NULL`, userID, key, lockedUntil)
+	return err
+}
+
+// Complete сохраняет ответ на запрос.
+func (r *IdempotencyRepo) Complete(ctx context.Context, record *domain.IdempotencyRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3, locked_until = NULL
+		WHERE user_id = $4 AND key = $5`,
+		record.StatusCode, record.ContentType, record.Body, record.UserID, record.Key)
+	return err
+}
+
+// Release удаляет ключ, чтобы клиент мог повторить запрос.
+func (r *IdempotencyRepo) Release(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	return err
+}
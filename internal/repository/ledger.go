@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+
+	"gophermart/internal/domain"
+)
+
+// ledgerPosting описывает операцию по счету пользователя для записи в журнал проводок.
+type ledgerPosting struct {
+	UserID         int
+	Direction      domain.LedgerDirection // направление со стороны счета пользователя
+	Amount         domain.Money
+	CounterAccount string // системный счет, участвующий в проводке с другой стороны
+	ReferenceType  domain.LedgerReferenceType
+	ReferenceID    int
+	WithdrawnDelta domain.Money // изменение суммы списаний в кэше баланса
+}
+
+// postLedger записывает проводку (две записи по двойной записи) и обновляет кэш баланса.
+// Вызывается внутри транзакции, в которой изменяются исходные данные операции.
+func postLedger(ctx context.Context, q sqlx.ExtContext, p ledgerPosting) error {
+	// Нулевые операции не меняют баланс, и журнал их не хранит
+	if p.Amount <= 0 {
+		return nil
+	}
+
+	var postingID int64
+	if err := sqlx.GetContext(ctx, q, &postingID, `SELECT nextval('ledger_posting_seq')`); err != nil {
+		return fmt.Errorf("failed to allocate ledger posting id: %w", err)
+	}
+
+	counterDirection := domain.LedgerDebit
+	balanceDelta := p.Amount
+	if p.Direction == domain.LedgerDebit {
+		counterDirection = domain.LedgerCredit
+		balanceDelta = -p.Amount
+	}
+
+	insertQuery := `
+		INSERT INTO ledger_entries
+			(posting_id, account, user_id, direction, amount_kop, reference_type, reference_id)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7),
+			($1, $8, NULL, $9, $5, $6, $7)`
+	if _, err := q.ExecContext(
+		ctx,
+		insertQuery,
+		postingID,
+		domain.UserLedgerAccount(p.UserID),
+		p.UserID,
+		p.Direction,
+		p.Amount,
+		p.ReferenceType,
+		p.ReferenceID,
+		p.CounterAccount,
+		counterDirection,
+	); err != nil {
+		return fmt.Errorf("failed to insert ledger entries: %w", err)
+	}
+
+	balanceQuery := `
+		INSERT INTO balances (user_id, current_kop, withdrawn_kop)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			current_kop = balances.current_kop + EXCLUDED.current_kop,
+			withdrawn_kop = balances.withdrawn_kop + EXCLUDED.withdrawn_kop,
+			updated_at = CURRENT_TIMESTAMP`
+	if _, err := q.ExecContext(ctx, balanceQuery, p.UserID, balanceDelta, p.WithdrawnDelta); err != nil {
+		return fmt.Errorf("failed to update balance cache: %w", err)
+	}
+
+	return nil
+}
+
+// LedgerRepo реализует интерфейс domain.LedgerRepository.
+type LedgerRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewLedgerRepo создает новый экземпляр LedgerRepo.
+func NewLedgerRepo(db *sqlx.DB, logger *slog.Logger) *LedgerRepo {
+	return &LedgerRepo{
+		db: db,
+		logger: logger.With(
+			"package", "repository",
+			"component", "LedgerRepo",
+		),
+	}
+}
+
+// FindDiscrepancies сравнивает журнал проводок с таблицами orders, withdrawals, balance_adjustments и balances.
+// Возвращенные списания не учитываются в сумме списаний ни в журнале, ни в исходных таблицах.
+func (r *LedgerRepo) FindDiscrepancies() ([]domain.LedgerDiscrepancy, error) {
+	logger := r.logger.With("method", "FindDiscrepancies")
+
+	query := `
+		WITH ledger AS (
+			SELECT user_id,
+				COALESCE(SUM(amount_kop) FILTER (
+					WHERE direction = 'CREDIT' AND reference_type = 'order'), 0) AS accrued_kop,
+				COALESCE(SUM(amount_kop) FILTER (
+					WHERE direction = 'DEBIT' AND reference_type = 'withdrawal'), 0)
+				- COALESCE(SUM(amount_kop) FILTER (
+					WHERE direction = 'CREDIT' AND reference_type = 'withdrawal_reversal'), 0) AS withdrawn_kop,
+				COALESCE(SUM(CASE direction WHEN 'CREDIT' THEN amount_kop ELSE -amount_kop END) FILTER (
+					WHERE reference_type = 'adjustment'), 0) AS adjusted_kop,
+				COALESCE(SUM(CASE direction WHEN 'CREDIT' THEN amount_kop ELSE -amount_kop END), 0) AS current_kop
+			FROM ledger_entries
+			WHERE user_id IS NOT NULL
+			GROUP BY user_id
+		),
+		accrued AS (
+			SELECT user_id, COALESCE(SUM(accrual), 0) AS kop
+			FROM orders
+			WHERE status = 'PROCESSED'
+			GROUP BY user_id
+		),
+		withdrawn AS (
+			SELECT w.user_id, COALESCE(SUM(w.amount_kop), 0) AS kop
+			FROM withdrawals w
+			LEFT JOIN balance_adjustments a ON a.withdrawal_id = w.id
+			WHERE a.id IS NULL
+			GROUP BY w.user_id
+		),
+		adjusted AS (
+			SELECT user_id, COALESCE(SUM(CASE direction WHEN 'CREDIT' THEN amount_kop ELSE -amount_kop END), 0) AS kop
+			FROM balance_adjustments
+			WHERE kind = 'adjustment'
+			GROUP BY user_id
+		),
+		users_all AS (
+			SELECT id AS user_id FROM users
+		)
+		SELECT u.user_id, 'orders_vs_ledger' AS check_name,
+			COALESCE(a.kop, 0)::bigint AS expected_kop, COALESCE(l.accrued_kop, 0)::bigint AS actual_kop
+		FROM users_all u
+		LEFT JOIN accrued a ON a.user_id = u.user_id
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		WHERE COALESCE(a.kop, 0) <> COALESCE(l.accrued_kop, 0)
+		UNION ALL
+		SELECT u.user_id, 'withdrawals_vs_ledger',
+			COALESCE(w.kop, 0)::bigint, COALESCE(l.withdrawn_kop, 0)::bigint
+		FROM users_all u
+		LEFT JOIN withdrawn w ON w.user_id = u.user_id
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		WHERE COALESCE(w.kop, 0) <> COALESCE(l.withdrawn_kop, 0)
+		UNION ALL
+		SELECT u.user_id, 'adjustments_vs_ledger',
+			COALESCE(ad.kop, 0)::bigint, COALESCE(l.adjusted_kop, 0)::bigint
+		FROM users_all u
+		LEFT JOIN adjusted ad ON ad.user_id = u.user_id
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		WHERE COALESCE(ad.kop, 0) <> COALESCE(l.adjusted_kop, 0)
+		UNION ALL
+		SELECT u.user_id, 'balance_cache_current',
+			COALESCE(l.current_kop, 0)::bigint, COALESCE(b.current_kop, 0)::bigint
+		FROM users_all u
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		LEFT JOIN balances b ON b.user_id = u.user_id
+		WHERE COALESCE(l.current_kop, 0) <> COALESCE(b.current_kop, 0)
+		UNION ALL
+		SELECT u.user_id, 'balance_cache_withdrawn',
+			COALESCE(l.withdrawn_kop, 0)::bigint, COALESCE(b.withdrawn_kop, 0)::bigint
+		FROM users_all u
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		LEFT JOIN balances b ON b.user_id = u.user_id
+		WHERE COALESCE(l.withdrawn_kop, 0) <> COALESCE(b.withdrawn_kop, 0)
+		UNION ALL
+		SELECT COALESCE(MAX(user_id), 0), 'unbalanced_posting',
+			COALESCE(SUM(amount_kop) FILTER (WHERE direction = 'DEBIT'), 0)::bigint,
+			COALESCE(SUM(amount_kop) FILTER (WHERE direction = 'CREDIT'), 0)::bigint
+		FROM ledger_entries
+		GROUP BY posting_id
+		HAVING COALESCE(SUM(amount_kop) FILTER (WHERE direction = 'DEBIT'), 0)
+			<> COALESCE(SUM(amount_kop) FILTER (WHERE direction = 'CREDIT'), 0)
+		ORDER BY 1, 2`
+
+	var discrepancies []domain.LedgerDiscrepancy
+	if err := r.db.Select(&discrepancies, query); err != nil {
+		logger.Error("ошибка проверки журнала проводок", "error", err)
+		return nil, err
+	}
+
+	logger.Debug("проверка журнала проводок завершена", "расхождений", len(discrepancies))
+	return discrepancies, nil
+}
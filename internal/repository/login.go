@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"gophermart/internal/domain"
+)
+
+// LoginThrottleRepo реализует интерфейс domain.LoginThrottleRepository.
+type LoginThrottleRepo struct {
+	db *sqlx.DB
+}
+
+// NewLoginThrottleRepo создает новый экземпляр LoginThrottleRepo.
+func NewLoginThrottleRepo(db *sqlx.DB) *LoginThrottleRepo {
+	return &LoginThrottleRepo{db: db}
+}
+
+// Find возвращает счетчики для логина и IP-адреса, если они есть.
+func (r *LoginThrottleRepo) Find(login, ip string) ([]domain.LoginThrottle, error) {
+	var throttles []domain.LoginThrottle
+	err := r.db.Select(&throttles, `
+		SELECT scope, key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE (scope = $1 AND key = $2) OR (scope = $3 AND key = $4)`,
+		domain.LoginThrottleByLogin, login, domain.LoginThrottleByIP, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find login throttles: %w", err)
+	}
+	return throttles, nil
+}
+
+// RecordFailure увеличивает счетчик неудачных входов.
+func (r *LoginThrottleRepo) RecordFailure(
+	scope domain.LoginThrottleScope,
+	key string,
+	resetAfter time.Duration,
+) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	err := r.db.Get(&throttle, `
+		INSERT INTO login_throttles (scope, key, failures)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $3) THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = CURRENT_TIMESTAMP
+		RETURNING scope, key, failures, last_failure_at, locked_until`,
+		scope, key, resetAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return &throttle, nil
+}
+
+// Lock блокирует вход до указанного времени.
+func (r *LoginThrottleRepo) Lock(scope domain.LoginThrottleScope, key string, until time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE login_throttles SET locked_until = $3
+		WHERE scope = $1 AND key = $2`, scope, key, until)
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return nil
+}
+
+// Reset сбрасывает счетчик после успешного входа.
+func (r *LoginThrottleRepo) Reset(scope domain.LoginThrottleScope, key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 
 	"gophermart/internal/domain"
 )
@@ -27,95 +30,352 @@ func NewOrderRepo(db *sqlx.DB, logger *slog.Logger) *OrderRepo {
 }
 
 // Create создает новый заказ.
-func (r *OrderRepo) Create(order *domain.Order) error {
+func (r *OrderRepo) Create(ctx context.Context, order *domain.Order) (err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.Create", attribute.String("order.number", order.Number))
+	defer func() { endSpan(span, err) }()
+
 	query := `
-		INSERT INTO orders (number, user_id, status)
-		VALUES ($1, $2, $3)
+		INSERT INTO orders (number, user_id, status, trace_parent)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, uploaded_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(
+		ctx,
 		query,
 		order.Number,
 		order.UserID,
 		order.Status,
+		order.TraceParent,
 	).Scan(&order.ID, &order.UploadedAt)
 }
 
 // FindByNumber ищет заказ по номеру.
-func (r *OrderRepo) FindByNumber(number string) (*domain.Order, error) {
+func (r *OrderRepo) FindByNumber(ctx context.Context, number string) (_ *domain.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.FindByNumber", attribute.String("order.number", number))
+	defer func() { endSpan(span, err) }()
+
 	var order domain.Order
 	query := `SELECT * FROM orders WHERE number = $1`
-	err := r.db.Get(&order, query, number)
+	err = r.db.GetContext(ctx, &order, query, number)
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-// FindByUserID возвращает все заказы пользователя.
-func (r *OrderRepo) FindByUserID(userID int) ([]domain.Order, error) {
+// FindByUserID возвращает заказы пользователя по фильтру.
+func (r *OrderRepo) FindByUserID(
+	ctx context.Context,
+	userID int,
+	filter domain.OrderFilter,
+) (_ []domain.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.FindByUserID", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	var q listQuery
+	q.where("user_id = $%d", userID)
+	if len(filter.Statuses) > 0 {
+		statusStrings := make([]string, len(filter.Statuses))
+		for i, s := range filter.Statuses {
+			statusStrings[i] = string(s)
+		}
+		q.where("status = ANY($%d)", statusStrings)
+	}
+	query := q.build(`SELECT * FROM orders`, filter.ListFilter, "uploaded_at", "id")
+
 	var orders []domain.Order
-	query := `
-		SELECT * FROM orders 
-		WHERE user_id = $1 
-		ORDER BY uploaded_at DESC`
-	err := r.db.Select(&orders, query, userID)
+	err = r.db.SelectContext(ctx, &orders, query, q.args...)
 	if err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
-// UpdateStatus обновляет статус заказа.
-func (r *OrderRepo) UpdateStatus(orderID int, status domain.OrderStatus) error {
+// FindStatusHistory возвращает историю смены статусов заказа.
+func (r *OrderRepo) FindStatusHistory(ctx context.Context, orderID int) (_ []domain.OrderStatusChange, err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.FindStatusHistory", attribute.Int("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	history := []domain.OrderStatusChange{}
 	query := `
-		UPDATE orders 
-		SET status = $1 
-		WHERE id = $2`
-	_, err := r.db.Exec(query, status, orderID)
-	return err
+		SELECT from_status, to_status, accrual, reason, attempt, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY id`
+	if err = r.db.SelectContext(ctx, &history, query, orderID); err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
-// UpdateAccrual обновляет сумму начисленных баллов за заказ.
-func (r *OrderRepo) UpdateAccrual(orderID int, accrualKop int64) error {
-	logger := r.logger.With("method", "UpdateAccrual")
-	logger.Info("обновление статуса на PROCESSED",
+// ApplyAccrual применяет ответ системы начислений к заказу в одной транзакции.
+// Строка заказа блокируется до конца транзакции, поэтому ответы, пришедшие параллельно
+// или не по порядку, применяются последовательно и не возвращают заказ в прежний статус.
+func (r *OrderRepo) ApplyAccrual(
+	ctx context.Context,
+	orderID int,
+	status domain.OrderStatus,
+	accrual *domain.Money,
+) (err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.ApplyAccrual",
+		attribute.Int("order.id", orderID),
+		attribute.String("order.status", string(status)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	logger := r.logger.With("method", "ApplyAccrual")
+	logger.Info("применение ответа системы начислений",
 		"id заказа", orderID,
-		"начисление (коп)", accrualKop)
+		"статус", status,
+		"начисление", accrual)
+
+	tx, beginErr := r.db.BeginTxx(ctx, nil)
+	if beginErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	current, err := lockOrder(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if current.Status == status && !status.IsFinal() {
+		return nil
+	}
+	if err = domain.ValidateOrderTransition(current.Status, status); err != nil {
+		logger.Warn("переход отклонен", "id заказа", orderID, "текущий статус", current.Status, "статус", status)
+		return err
+	}
+
+	// Запрос с окончательным ответом тоже учитывается: повторных опросов, которые бы его посчитали, не будет
+	attemptsDelta := 0
+	if status.IsFinal() {
+		attemptsDelta = 1
+	}
 
 	query := `
-		UPDATE orders 
-		SET accrual = $1, status = $2 
-		WHERE id = $3`
-	_, err := r.db.Exec(query, accrualKop, domain.OrderStatusProcessed, orderID)
-	return err
+		UPDATE orders
+		SET status = $1, accrual = $2, attempts = attempts + $3
+		WHERE id = $4`
+	if _, err = tx.ExecContext(ctx, query, status, accrual, attemptsDelta, orderID); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if status == domain.OrderStatusProcessed && accrual != nil {
+		if ledgerErr := postLedger(ctx, tx, ledgerPosting{
+			UserID:         current.UserID,
+			Direction:      domain.LedgerCredit,
+			Amount:         *accrual,
+			CounterAccount: domain.LedgerAccountAccrual,
+			ReferenceType:  domain.LedgerReferenceOrder,
+			ReferenceID:    orderID,
+		}); ledgerErr != nil {
+			return ledgerErr
+		}
+	}
+
+	attempt := current.Attempts + 1
+	if err = recordStatusTransition(ctx, tx, orderID, current.Status, status, accrual, nil, &attempt); err != nil {
+		return err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("failed to commit transaction: %w", commitErr)
+	}
+
+	return nil
 }
 
-// FindByStatus возвращает заказы с указанными статусами.
-func (r *OrderRepo) FindByStatus(statuses []domain.OrderStatus) ([]domain.Order, error) {
-	logger := r.logger.With("method", "FindByStatus")
+// lockOrder блокирует строку заказа до конца транзакции и возвращает его текущий статус,
+// владельца и количество запросов в систему начислений.
+func lockOrder(ctx context.Context, tx *sqlx.Tx, orderID int) (*domain.Order, error) {
+	var order domain.Order
+	query := `SELECT id, user_id, status, attempts FROM orders WHERE id = $1 FOR UPDATE`
+	if err := tx.GetContext(ctx, &order, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+	return &order, nil
+}
+
+// recordStatusTransition записывает смену статуса заказа в историю.
+// attempt - номер запроса в систему начислений, ответ на который сменил статус, или nil.
+func recordStatusTransition(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	orderID int,
+	from, to domain.OrderStatus,
+	accrual *domain.Money,
+	reason *string,
+	attempt *int,
+) error {
+	query := `
+		INSERT INTO order_status_history (order_id, from_status, to_status, accrual, reason, attempt)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.ExecContext(ctx, query, orderID, from, to, accrual, reason, attempt); err != nil {
+		return fmt.Errorf("failed to record order status transition: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue захватывает аренду на пачку заказов, время опроса которых наступило.
+// SELECT ... FOR UPDATE SKIP LOCKED пропускает строки, которые в этот момент захватывает
+// другой воркер или реплика, а условие по locked_until - заказы с действующей арендой.
+func (r *OrderRepo) ClaimDue(
+	ctx context.Context,
+	statuses []domain.OrderStatus,
+	limit int,
+	lease time.Duration,
+) (_ []domain.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.ClaimDue")
+	defer func() { endSpan(span, err) }()
+
+	logger := r.logger.With("method", "ClaimDue")
 
-	// Преобразуем OrderStatus в []string для запроса к БД и логирования
 	statusStrings := make([]string, len(statuses))
 	for i, s := range statuses {
 		statusStrings[i] = string(s)
 	}
 
 	query := `
-		SELECT * FROM orders 
-		WHERE status = ANY($1)
-		ORDER BY uploaded_at ASC`
+		UPDATE orders
+		SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $3)
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status = ANY($1)
+				AND next_attempt_at <= CURRENT_TIMESTAMP
+				AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+			ORDER BY next_attempt_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
 
 	var orders []domain.Order
-	err := r.db.Select(&orders, query, statusStrings)
-	if err != nil {
-		logger.Error("ошибка при поиске заказов",
+	if err = r.db.SelectContext(ctx, &orders, query, statusStrings, limit, lease.Seconds()); err != nil {
+		logger.Error("ошибка при захвате заказов",
 			"error", err,
 			"тип ошибки", fmt.Sprintf("%T", err),
 		)
 		return nil, err
 	}
 
-	logger.Debug("поиск заказов по статусам", "статусы", statusStrings, "количество", len(orders))
+	span.SetAttributes(attribute.Int("orders.claimed", len(orders)))
+	logger.Debug("захвачены заказы", "статусы", statusStrings, "количество", len(orders))
 	return orders, nil
 }
+
+// ReleaseClaim снимает аренду с заказа.
+func (r *OrderRepo) ReleaseClaim(ctx context.Context, orderID int) (err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.ReleaseClaim", attribute.Int("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.db.ExecContext(ctx, `UPDATE orders SET locked_until = NULL WHERE id = $1`, orderID)
+	return err
+}
+
+// ScheduleRetry фиксирует неудачную попытку, назначает время следующего опроса и снимает аренду.
+func (r *OrderRepo) ScheduleRetry(
+	ctx context.Context,
+	orderID int,
+	nextAttemptAt time.Time,
+	lastError string,
+) (err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.ScheduleRetry", attribute.Int("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		UPDATE orders
+		SET attempts = attempts + 1,
+			last_error = $1,
+			next_attempt_at = $2,
+			locked_until = NULL
+		WHERE id = $3`
+	_, err = r.db.ExecContext(ctx, query, lastError, nextAttemptAt, orderID)
+	return err
+}
+
+// MarkFailed переводит необработанный заказ в статус INVALID с указанием причины.
+func (r *OrderRepo) MarkFailed(ctx context.Context, orderID int, reason string) (err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.MarkFailed", attribute.Int("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+
+	logger := r.logger.With("method", "MarkFailed")
+	logger.Warn("прекращение опроса заказа", "id заказа", orderID, "причина", reason)
+
+	tx, beginErr := r.db.BeginTxx(ctx, nil)
+	if beginErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	current, err := lockOrder(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if err = domain.ValidateOrderTransition(current.Status, domain.OrderStatusInvalid); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE orders
+		SET status = $1,
+			failure_reason = $2,
+			locked_until = NULL
+		WHERE id = $3`
+	if _, err = tx.ExecContext(ctx, query, domain.OrderStatusInvalid, reason, orderID); err != nil {
+		return fmt.Errorf("failed to mark order failed: %w", err)
+	}
+
+	if err = recordStatusTransition(
+		ctx,
+		tx,
+		orderID,
+		current.Status,
+		domain.OrderStatusInvalid,
+		nil,
+		&reason,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("failed to commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+// QueueStats возвращает количество заказов по статусам и возраст самого старого необработанного заказа.
+func (r *OrderRepo) QueueStats(ctx context.Context) (_ *domain.OrderQueueStats, err error) {
+	ctx, span := startSpan(ctx, "OrderRepo.QueueStats")
+	defer func() { endSpan(span, err) }()
+
+	var rows []struct {
+		Status domain.OrderStatus `db:"status"`
+		Count  int                `db:"count"`
+	}
+	if err = r.db.SelectContext(ctx, &rows, `SELECT status, COUNT(*) AS count FROM orders GROUP BY status`); err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+
+	stats := &domain.OrderQueueStats{Counts: make(map[domain.OrderStatus]int, len(rows))}
+	for _, row := range rows {
+		stats.Counts[row.Status] = row.Count
+	}
+
+	var ageSeconds float64
+	if err = r.db.GetContext(ctx, &ageSeconds, `
+		SELECT COALESCE(EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - MIN(uploaded_at)), 0)::float8
+		FROM orders
+		WHERE status IN ($1, $2)`, domain.OrderStatusNew, domain.OrderStatusProcessing); err != nil {
+		return nil, fmt.Errorf("failed to find oldest pending order: %w", err)
+	}
+	stats.OldestPendingAge = time.Duration(ageSeconds * float64(time.Second))
+
+	return stats, nil
+}
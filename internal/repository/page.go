@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"gophermart/internal/domain"
+)
+
+// listQuery собирает условия запроса списка и их аргументы.
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where добавляет условие. Вместо %d в условие подставляются номера параметров args.
+func (q *listQuery) where(condition string, args ...interface{}) {
+	positions := make([]interface{}, len(args))
+	for i, arg := range args {
+		q.args = append(q.args, arg)
+		positions[i] = len(q.args)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, positions...))
+}
+
+// build дополняет запрос условиями периода, курсора, сортировки и размера страницы из filter.
+// Записи упорядочены по timeColumn, при равном времени - по idColumn, поэтому курсор однозначен.
+func (q *listQuery) build(base string, filter domain.ListFilter, timeColumn, idColumn string) string {
+	if filter.Since != nil {
+		q.where(timeColumn+" >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		q.where(timeColumn+" < $%d", *filter.Until)
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Sort == domain.SortOldestFirst {
+		direction, comparison = "ASC", ">"
+	}
+	if filter.After != nil {
+		q.where(
+			fmt.Sprintf("(%s, %s) %s ($%%d, $%%d)", timeColumn, idColumn, comparison),
+			filter.After.Time,
+			filter.After.ID,
+		)
+	}
+
+	query := base
+	if len(q.conditions) > 0 {
+		query += ` WHERE ` + strings.Join(q.conditions, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, %s %s`, timeColumn, direction, idColumn, direction)
+	if filter.Limit > 0 {
+		q.args = append(q.args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(q.args))
+	}
+
+	return query
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"gophermart/internal/domain"
+)
+
+// SessionRepo реализует интерфейс domain.SessionRepository.
+type SessionRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewSessionRepo создает новый экземпляр SessionRepo.
+func NewSessionRepo(db *sqlx.DB, logger *slog.Logger) *SessionRepo {
+	return &SessionRepo{
+		db: db,
+		logger: logger.With(
+			"package", "repository",
+			"component", "SessionRepo",
+		),
+	}
+}
+
+// refreshTokenRow refresh-токен вместе с состоянием его сессии.
+type refreshTokenRow struct {
+	ID        int64      `db:"id"`
+	SessionID int64      `db:"session_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// Create создает сессию с первым refresh-токеном.
+func (r *SessionRepo) Create(userID int, tokenHash string, tokenExpiresAt time.Time) (*domain.Session, error) {
+	tx, beginErr := r.db.Beginx()
+	if beginErr != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var session domain.Session
+	if err := tx.Get(&session, `
+		INSERT INTO sessions (user_id)
+		VALUES ($1)
+		RETURNING *`, userID); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)`, session.ID, tokenHash, tokenExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &session, nil
+}
+
+// Rotate обменивает refresh-токен на новый в рамках той же сессии.
+func (r *SessionRepo) Rotate(tokenHash, newTokenHash string, newExpiresAt time.Time) (*domain.Session, error) {
+	logger := r.logger.With("method", "Rotate")
+
+	tx, beginErr := r.db.Beginx()
+	if beginErr != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Блокируем сессию, чтобы одновременные обмены одного токена не выдали две пары
+	var token refreshTokenRow
+	err := tx.Get(&token, `
+		SELECT t.id, t.session_id, t.expires_at, t.used_at, s.revoked_at
+		FROM refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF s`, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	if token.RevokedAt != nil {
+		return nil, domain.ErrSessionRevoked
+	}
+
+	// Токен уже обменивался: скорее всего, он украден, поэтому отзываем всю цепочку
+	if token.UsedAt != nil {
+		if revokeErr := revokeSession(tx, token.SessionID, domain.SessionRevokeTokenReused); revokeErr != nil {
+			return nil, revokeErr
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", commitErr)
+		}
+		logger.Warn("повторное использование refresh-токена, сессия отозвана", "session_id", token.SessionID)
+		return nil, domain.ErrRefreshTokenReused
+	}
+
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+
+	if _, execErr := tx.Exec(`UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		token.ID); execErr != nil {
+		return nil, fmt.Errorf("failed to mark refresh token used: %w", execErr)
+	}
+
+	if _, execErr := tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)`, token.SessionID, newTokenHash, newExpiresAt); execErr != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", execErr)
+	}
+
+	var session domain.Session
+	if getErr := tx.Get(&session, `
+		UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING *`, token.SessionID); getErr != nil {
+		return nil, fmt.Errorf("failed to update session: %w", getErr)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", commitErr)
+	}
+
+	return &session, nil
+}
+
+// Authorize проверяет, что сессия пользователя не отозвана, а сам пользователь не заблокирован,
+// и возвращает его текущую роль.
+func (r *SessionRepo) Authorize(sessionID int64, userID int) (domain.UserRole, bool, error) {
+	var role domain.UserRole
+	err := r.db.Get(&role, `
+		SELECT u.role FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND u.blocked_at IS NULL`,
+		sessionID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return role, true, nil
+}
+
+// Revoke отзывает сессию пользователя.
+func (r *SessionRepo) Revoke(sessionID int64, userID int, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAll отзывает все сессии пользователя.
+func (r *SessionRepo) RevokeAll(userID int, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// revokeSession отзывает сессию в рамках транзакции.
+func revokeSession(tx *sqlx.Tx, sessionID int64, reason string) error {
+	if _, err := tx.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL`, sessionID, reason); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 
 	"gophermart/internal/domain"
 )
@@ -17,13 +23,17 @@ func NewUserRepo(db *sqlx.DB) *UserRepo {
 }
 
 // Create добавляет нового пользователя в базу данных.
-func (r *UserRepo) Create(user *domain.User) error {
+func (r *UserRepo) Create(ctx context.Context, user *domain.User) (err error) {
+	ctx, span := startSpan(ctx, "UserRepo.Create")
+	defer func() { endSpan(span, err) }()
+
 	query := `
 		INSERT INTO users (login, password_hash)
 		VALUES ($1, $2)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(
+		ctx,
 		query,
 		user.Login,
 		user.PasswordHash,
@@ -31,12 +41,151 @@ func (r *UserRepo) Create(user *domain.User) error {
 }
 
 // FindByLogin ищет пользователя по логину.
-func (r *UserRepo) FindByLogin(login string) (*domain.User, error) {
+func (r *UserRepo) FindByLogin(ctx context.Context, login string) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserRepo.FindByLogin")
+	defer func() { endSpan(span, err) }()
+
 	var user domain.User
 	query := `SELECT * FROM users WHERE login = $1`
-	err := r.db.Get(&user, query, login)
+	err = r.db.GetContext(ctx, &user, query, login)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdatePassword сохраняет новый хеш пароля пользователя.
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID int, passwordHash string) (err error) {
+	ctx, span := startSpan(ctx, "UserRepo.UpdatePassword", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`
+	_, err = r.db.ExecContext(ctx, query, passwordHash, userID)
+	return err
+}
+
+// ChangePassword сохраняет новый хеш пароля и отзывает все сессии пользователя в одной транзакции,
+// чтобы старые токены не пережили смену пароля.
+func (r *UserRepo) ChangePassword(
+	ctx context.Context,
+	userID int,
+	passwordHash string,
+	revokeReason string,
+) (err error) {
+	ctx, span := startSpan(ctx, "UserRepo.ChangePassword", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	tx, beginErr := r.db.BeginTxx(ctx, nil)
+	if beginErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", beginErr)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`, passwordHash, userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID, revokeReason); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("failed to commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+// FindByID ищет пользователя по идентификатору.
+func (r *UserRepo) FindByID(ctx context.Context, id int) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserRepo.FindByID", attribute.Int("user.id", id))
+	defer func() { endSpan(span, err) }()
+
+	var user domain.User
+	query := `SELECT * FROM users WHERE id = $1`
+	err = r.db.GetContext(ctx, &user, query, id)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+// Search ищет пользователей по части логина.
+func (r *UserRepo) Search(ctx context.Context, query string, limit, offset int) (_ []domain.UserInfo, err error) {
+	ctx, span := startSpan(ctx, "UserRepo.Search")
+	defer func() { endSpan(span, err) }()
+
+	users := make([]domain.UserInfo, 0)
+	err = r.db.SelectContext(ctx, &users, `
+		SELECT id, login, role, created_at, blocked_at, blocked_reason
+		FROM users
+		WHERE $1 = '' OR login ILIKE '%' || $1 || '%'
+		ORDER BY id
+		LIMIT $2 OFFSET $3`, escapeLike(query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
+}
+
+// SetBlocked блокирует или разблокирует пользователя.
+func (r *UserRepo) SetBlocked(ctx context.Context, userID int, blocked bool, reason string) (err error) {
+	ctx, span := startSpan(ctx, "UserRepo.SetBlocked", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		UPDATE users SET blocked_at = CURRENT_TIMESTAMP, blocked_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	args := []any{userID, reason}
+	if !blocked {
+		query = `
+			UPDATE users SET blocked_at = NULL, blocked_reason = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1`
+		args = args[:1]
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user block: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetRole назначает роль пользователю.
+func (r *UserRepo) SetRole(ctx context.Context, userID int, role domain.UserRole) (err error) {
+	ctx, span := startSpan(ctx, "UserRepo.SetRole", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// requireAffected возвращает sql.ErrNoRows, если запрос не изменил ни одной строки.
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
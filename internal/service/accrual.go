@@ -5,95 +5,247 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"time"
 
-	"gophermart/internal/domain"
-)
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
-var (
-	// ErrOrderNotFound ошибка заказ не найден в системе начислений.
-	ErrOrderNotFound = errors.New("заказ не найден в системе начислений")
+	"gophermart/internal/domain"
 )
 
 // AccrualResponse представляет ответ от системы начислений.
 type AccrualResponse struct {
 	Order   string             `json:"order"`
 	Status  domain.OrderStatus `json:"status"`
-	Accrual *float64           `json:"accrual,omitempty"`
+	Accrual *domain.Money      `json:"accrual,omitempty"`
 }
 
 const (
 	defaultClientTimeout = 10 * time.Second
-	defaultRetryTimeout  = 60 * time.Second
+	// maxRateLimitBodySize ограничивает чтение тела ответа 429 с подсказкой о лимите.
+	maxRateLimitBodySize = 1024
+	// accrualTracerName имя трассировщика запросов к системе начислений.
+	accrualTracerName = "gophermart/internal/service/accrual"
 )
 
-// AccrualService сервис для взаимодействия с системой начислений.
+// AccrualConfig содержит настройки клиента системы начислений.
+type AccrualConfig struct {
+	BaseURL        string               // Адрес системы начислений
+	RateLimit      float64              // Темп запросов в секунду для всех воркеров (0 - без ограничения до первого 429)
+	CircuitBreaker CircuitBreakerConfig // Пороги автоматического выключателя
+}
+
+// AccrualService HTTP-реализация domain.AccrualClient для взаимодействия с системой начислений.
+// Один экземпляр разделяется всеми воркерами, поэтому ограничение темпа запросов общее.
 type AccrualService struct {
 	client  *http.Client
 	baseURL string
+	limiter *rateLimiter
+	breaker *circuitBreaker
+	metrics domain.AccrualMetrics
+	logger  *slog.Logger
+}
+
+// accrualStatusError ошибка система начислений ответила неожиданным кодом.
+type accrualStatusError struct {
+	StatusCode int
+}
+
+func (e *accrualStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
 }
 
 // NewAccrualService создает новый экземпляр AccrualService.
-func NewAccrualService(baseURL string) *AccrualService {
+func NewAccrualService(cfg AccrualConfig, metrics domain.AccrualMetrics, logger *slog.Logger) *AccrualService {
+	logger = logger.With(
+		"package", "service",
+		"component", "AccrualService",
+	)
+
 	return &AccrualService{
 		client: &http.Client{
 			Timeout: defaultClientTimeout,
 		},
-		baseURL: baseURL,
+		baseURL: cfg.BaseURL,
+		limiter: newRateLimiter(cfg.RateLimit, logger),
+		breaker: newCircuitBreaker(cfg.CircuitBreaker, logger),
+		metrics: metrics,
+		logger:  logger,
 	}
 }
 
 // GetOrderAccrual получает информацию о начислении баллов за заказ.
+// Пока цепь автоматического выключателя разомкнута, сразу возвращает domain.ErrAccrualCircuitOpen.
+// Перед запросом ожидает разрешения общего ограничителя; ожидание прерывается отменой контекста.
 func (s *AccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.OrderAccrual, error) {
+	ctx, span := otel.Tracer(accrualTracerName).Start(ctx, "AccrualService.GetOrderAccrual",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("order.number", orderNumber)),
+	)
+	defer span.End()
+
+	if allowErr := s.breaker.Allow(); allowErr != nil {
+		s.metrics.ObserveAccrualRequest(domain.AccrualOutcomeCircuitOpen, 0)
+		span.SetAttributes(attribute.String("accrual.outcome", domain.AccrualOutcomeCircuitOpen))
+		span.SetStatus(codes.Error, allowErr.Error())
+		return nil, allowErr
+	}
+
+	if waitErr := s.limiter.Wait(ctx); waitErr != nil {
+		s.breaker.Record(callIgnored, 0, waitErr)
+		span.SetStatus(codes.Error, waitErr.Error())
+		return nil, fmt.Errorf("rate limiter wait: %w", waitErr)
+	}
+
+	start := time.Now()
+	accrual, err := s.fetchOrderAccrual(ctx, orderNumber)
+	duration := time.Since(start)
+	outcome := accrualOutcome(err)
+	s.breaker.Record(classifyAccrualCall(ctx, err), duration, err)
+	s.metrics.ObserveAccrualRequest(outcome, duration)
+
+	span.SetAttributes(attribute.String("accrual.outcome", outcome))
+	if accrual != nil {
+		span.SetAttributes(attribute.String("accrual.status", string(accrual.Status)))
+	}
+	// Отсутствие заказа в системе начислений - штатный ответ, а не сбой
+	if err != nil && !errors.Is(err, domain.ErrAccrualOrderNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return accrual, err
+}
+
+// accrualOutcome определяет исход запроса к системе начислений для метрик.
+func accrualOutcome(err error) string {
+	var (
+		rateLimitErr *domain.AccrualRateLimitError
+		statusErr    *accrualStatusError
+	)
+	switch {
+	case err == nil:
+		return domain.AccrualOutcomeOK
+	case errors.Is(err, domain.ErrAccrualOrderNotFound):
+		return domain.AccrualOutcomeNoContent
+	case errors.As(err, &rateLimitErr):
+		return domain.AccrualOutcomeRateLimited
+	case errors.Is(err, domain.ErrAccrualMalformedResponse):
+		return domain.AccrualOutcomeMalformed
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError:
+		return domain.AccrualOutcomeServerError
+	case errors.As(err, &statusErr):
+		return domain.AccrualOutcomeClientError
+	default:
+		return domain.AccrualOutcomeTransport
+	}
+}
+
+// CircuitStatus возвращает состояние автоматического выключателя.
+func (s *AccrualService) CircuitStatus() domain.CircuitStatus {
+	return s.breaker.Status()
+}
+
+// CheckReachable проверяет готовность клиента системы начислений: цепь автоматического выключателя
+// не разомкнута или система начислений принимает соединения. Проверка не расходует квоту запросов.
+func (s *AccrualService) CheckReachable(ctx context.Context) error {
+	if s.breaker.Status().State != domain.CircuitOpen {
+		return nil
+	}
+
+	baseURL, parseErr := url.Parse(s.baseURL)
+	if parseErr != nil {
+		return fmt.Errorf("failed to parse accrual system address: %w", parseErr)
+	}
+	port := baseURL.Port()
+	if port == "" {
+		port = "80"
+		if baseURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	var dialer net.Dialer
+	conn, dialErr := dialer.DialContext(ctx, "tcp", net.JoinHostPort(baseURL.Hostname(), port))
+	if dialErr != nil {
+		return fmt.Errorf("circuit is open and accrual system is unreachable: %w", dialErr)
+	}
+	return conn.Close()
+}
+
+// classifyAccrualCall определяет, как результат запроса влияет на автоматический выключатель.
+func classifyAccrualCall(ctx context.Context, err error) callOutcome {
+	var rateLimitErr *domain.AccrualRateLimitError
+	switch {
+	case err == nil, errors.Is(err, domain.ErrAccrualOrderNotFound), errors.As(err, &rateLimitErr):
+		return callSuccess
+	case ctx.Err() != nil:
+		return callIgnored
+	default:
+		return callFailure
+	}
+}
+
+// fetchOrderAccrual выполняет HTTP-запрос к системе начислений.
+func (s *AccrualService) fetchOrderAccrual(ctx context.Context, orderNumber string) (*domain.OrderAccrual, error) {
 	url := fmt.Sprintf("%s/api/orders/%s", s.baseURL, orderNumber)
 	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if reqErr != nil {
 		return nil, fmt.Errorf("failed to create request: %w", reqErr)
 	}
+	// Передаем контекст трассировки, чтобы запрос был виден и на стороне системы начислений
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, respErr := s.client.Do(req)
 	if respErr != nil {
 		return nil, fmt.Errorf("failed to do request: %w", respErr)
 	}
 	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	// Если заказ не найден, возвращаем специальную ошибку
 	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
-		return nil, ErrOrderNotFound
+		return nil, domain.ErrAccrualOrderNotFound
 	}
 
-	// Проверяем rate limit
+	// Превышен лимит: приостанавливаем всех вызывающих и подстраиваем темп под подсказку
 	if resp.StatusCode == http.StatusTooManyRequests {
-		retryAfter := resp.Header.Get("Retry-After")
-		if retryAfter != "" {
-			seconds, durationErr := time.ParseDuration(retryAfter + "s")
-			if durationErr != nil {
-				return nil, fmt.Errorf("failed to parse retry after: %w", durationErr)
-			}
-			return nil, &RateLimitError{RetryAfter: seconds}
-		}
+		return nil, s.handleRateLimit(resp)
 	}
 
 	// Проверяем успешность ответа
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &accrualStatusError{StatusCode: resp.StatusCode}
 	}
 
 	// Декодируем ответ
 	var accrual domain.OrderAccrual
 	if decodeErr := json.NewDecoder(resp.Body).Decode(&accrual); decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+		return nil, fmt.Errorf("%w: %w", domain.ErrAccrualMalformedResponse, decodeErr)
 	}
 
 	return &accrual, nil
 }
 
-// RateLimitError ошибка превышения лимита запросов.
-type RateLimitError struct {
-	RetryAfter time.Duration
-}
+// handleRateLimit обрабатывает ответ 429 и возвращает domain.AccrualRateLimitError.
+func (s *AccrualService) handleRateLimit(resp *http.Response) error {
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	s.limiter.Pause(retryAfter)
+	s.metrics.ObserveAccrualRetryAfter(retryAfter)
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxRateLimitBodySize))
+	if readErr != nil {
+		s.logger.Warn("не удалось прочитать тело ответа 429", "error", readErr)
+	}
+	s.limiter.AdaptToLimit(parseRateLimitHint(resp.Header, body))
 
-func (e *RateLimitError) Error() string {
-	return fmt.Sprintf("превышен лимит запросов, повторить через %v", e.RetryAfter)
+	return &domain.AccrualRateLimitError{RetryAfter: retryAfter}
 }
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	defaultUserSearchLimit = 50
+	maxUserSearchLimit     = 500
+)
+
+// AdminService реализует интерфейс domain.AdminService.
+type AdminService struct {
+	users    domain.UserRepository
+	orders   domain.OrderRepository
+	balances domain.BalanceRepository
+	sessions domain.SessionRepository
+	audit    domain.AuditRecorder
+	metrics  domain.BalanceMetrics
+	logger   *slog.Logger
+}
+
+// NewAdminService создает новый экземпляр AdminService.
+func NewAdminService(
+	users domain.UserRepository,
+	orders domain.OrderRepository,
+	balances domain.BalanceRepository,
+	sessions domain.SessionRepository,
+	audit domain.AuditRecorder,
+	metrics domain.BalanceMetrics,
+	logger *slog.Logger,
+) *AdminService {
+	return &AdminService{
+		users:    users,
+		orders:   orders,
+		balances: balances,
+		sessions: sessions,
+		audit:    audit,
+		metrics:  metrics,
+		logger: logger.With(
+			"package", "service",
+			"component", "AdminService",
+		),
+	}
+}
+
+// SearchUsers ищет пользователей по части логина.
+func (s *AdminService) SearchUsers(ctx context.Context, query string, limit, offset int) ([]domain.UserInfo, error) {
+	if limit <= 0 {
+		limit = defaultUserSearchLimit
+	}
+	limit = min(limit, maxUserSearchLimit)
+	offset = max(offset, 0)
+
+	return s.users.Search(ctx, query, limit, offset)
+}
+
+// GetUserOrders возвращает заказы пользователя.
+func (s *AdminService) GetUserOrders(ctx context.Context, userID int) ([]domain.Order, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.orders.FindByUserID(ctx, userID, domain.OrderFilter{})
+}
+
+// GetUserWithdrawals возвращает списания пользователя вместе с их идентификаторами.
+func (s *AdminService) GetUserWithdrawals(ctx context.Context, userID int) ([]domain.WithdrawalRecord, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := s.balances.GetWithdrawals(ctx, userID, domain.WithdrawalFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]domain.WithdrawalRecord, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		records = append(records, domain.WithdrawalRecord{ID: withdrawal.ID, Withdrawal: withdrawal})
+	}
+	return records, nil
+}
+
+// GetUserAdjustments возвращает корректировки баланса пользователя.
+func (s *AdminService) GetUserAdjustments(ctx context.Context, userID int) ([]domain.Adjustment, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.balances.GetAdjustments(ctx, userID)
+}
+
+// AdjustBalance зачисляет или списывает баллы пользователя от имени оператора.
+// Списание не может увести баланс в минус.
+func (s *AdminService) AdjustBalance(
+	ctx context.Context,
+	operatorID, userID int,
+	req *domain.AdjustmentRequest,
+	meta domain.RequestMeta,
+) (*domain.Adjustment, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	adjustment := &domain.Adjustment{
+		UserID:     userID,
+		OperatorID: operatorID,
+		Kind:       domain.LedgerReferenceAdjustment,
+		Direction:  domain.LedgerCredit,
+		Amount:     req.Amount,
+		Reason:     req.Reason,
+	}
+	if req.Amount < 0 {
+		adjustment.Direction = domain.LedgerDebit
+		adjustment.Amount = -req.Amount
+	}
+
+	err := s.balances.WithBalanceLock(ctx, userID, func(tx domain.BalanceTx) error {
+		if adjustment.Direction == domain.LedgerDebit {
+			balance, err := tx.GetBalance(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if balance.Current < adjustment.Amount {
+				return ErrInsufficientFunds
+			}
+		}
+		return tx.CreateAdjustment(ctx, adjustment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("баланс скорректирован",
+		"user_id", userID,
+		"operator_id", operatorID,
+		"направление", adjustment.Direction,
+		"сумма", adjustment.Amount,
+		"причина", adjustment.Reason)
+
+	s.metrics.ObserveAdjustment(adjustment.Kind, adjustment.Direction, adjustment.Amount)
+	s.recordAdjustment(domain.AuditBalanceAdjusted, adjustment, meta)
+	return adjustment, nil
+}
+
+// ReverseWithdrawal возвращает пользователю баллы по списанию от имени оператора.
+// Каждое списание может быть возвращено только один раз.
+func (s *AdminService) ReverseWithdrawal(
+	ctx context.Context,
+	operatorID, userID, withdrawalID int,
+	reason string,
+	meta domain.RequestMeta,
+) (*domain.Adjustment, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	var adjustment *domain.Adjustment
+	err := s.balances.WithBalanceLock(ctx, userID, func(tx domain.BalanceTx) error {
+		withdrawal, err := tx.GetWithdrawal(ctx, userID, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.ReversedAt != nil {
+			return ErrWithdrawalReversed
+		}
+
+		adjustment = &domain.Adjustment{
+			UserID:       userID,
+			OperatorID:   operatorID,
+			Kind:         domain.LedgerReferenceWithdrawalReversal,
+			Direction:    domain.LedgerCredit,
+			Amount:       withdrawal.Sum,
+			WithdrawalID: &withdrawal.ID,
+			Reason:       reason,
+		}
+		return tx.CreateAdjustment(ctx, adjustment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("списание возвращено",
+		"user_id", userID,
+		"operator_id", operatorID,
+		"withdrawal_id", withdrawalID,
+		"сумма", adjustment.Amount,
+		"причина", reason)
+
+	s.metrics.ObserveAdjustment(adjustment.Kind, adjustment.Direction, adjustment.Amount)
+	s.recordAdjustment(domain.AuditWithdrawalReversed, adjustment, meta)
+	return adjustment, nil
+}
+
+// recordAdjustment записывает корректировку баланса в журнал аудита от имени оператора.
+func (s *AdminService) recordAdjustment(
+	action domain.AuditAction,
+	adjustment *domain.Adjustment,
+	meta domain.RequestMeta,
+) {
+	event := userAuditEvent(adjustment.OperatorID, action, meta)
+	event.TargetType = domain.AuditTargetAdjustment
+	event.TargetID = strconv.Itoa(adjustment.ID)
+	event.Payload = auditPayload(map[string]interface{}{
+		"user_id":       adjustment.UserID,
+		"direction":     adjustment.Direction,
+		"amount":        adjustment.Amount,
+		"withdrawal_id": adjustment.WithdrawalID,
+		"reason":        adjustment.Reason,
+	})
+	s.audit.Record(event)
+}
+
+// GetUserBalance возвращает баланс пользователя.
+func (s *AdminService) GetUserBalance(ctx context.Context, userID int) (*domain.Balance, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.balances.GetBalance(ctx, userID)
+}
+
+// BlockUser блокирует пользователя и отзывает все его сессии.
+// Уже выданные access-токены перестают приниматься сразу, так как JWTMiddleware проверяет блокировку.
+func (s *AdminService) BlockUser(
+	ctx context.Context,
+	operatorID,
+	userID int,
+	reason string,
+	meta domain.RequestMeta,
+) error {
+	if err := s.users.SetBlocked(ctx, userID, true, reason); err != nil {
+		return s.userError(err)
+	}
+
+	if err := s.sessions.RevokeAll(userID, domain.SessionRevokeUserBlocked); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.logger.Info("пользователь заблокирован", "user_id", userID, "причина", reason)
+
+	event := userAuditEvent(operatorID, domain.AuditUserBlocked, meta)
+	event.TargetID = strconv.Itoa(userID)
+	event.Payload = auditPayload(map[string]interface{}{"reason": reason})
+	s.audit.Record(event)
+	return nil
+}
+
+// UnblockUser снимает блокировку с пользователя.
+func (s *AdminService) UnblockUser(ctx context.Context, operatorID, userID int, meta domain.RequestMeta) error {
+	if err := s.users.SetBlocked(ctx, userID, false, ""); err != nil {
+		return s.userError(err)
+	}
+
+	s.logger.Info("пользователь разблокирован", "user_id", userID)
+
+	event := userAuditEvent(operatorID, domain.AuditUserUnblocked, meta)
+	event.TargetID = strconv.Itoa(userID)
+	s.audit.Record(event)
+	return nil
+}
+
+// requireUser проверяет, что пользователь существует.
+func (s *AdminService) requireUser(ctx context.Context, userID int) error {
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		return s.userError(err)
+	}
+	return nil
+}
+
+// userError преобразует отсутствие пользователя в ErrUserNotFound.
+func (s *AdminService) userError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	return err
+}
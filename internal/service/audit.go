@@ -0,0 +1,118 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+	// maxAuditViolations ограничивает число нарушений цепочки в ответе проверки.
+	maxAuditViolations = 100
+)
+
+// Auditor реализует интерфейс domain.AuditRecorder.
+// Ошибка записи события не прерывает операцию, а попадает в журнал приложения вместе с событием.
+type Auditor struct {
+	repo   domain.AuditRepository
+	logger *slog.Logger
+}
+
+// NewAuditor создает новый экземпляр Auditor.
+func NewAuditor(repo domain.AuditRepository, logger *slog.Logger) *Auditor {
+	return &Auditor{
+		repo: repo,
+		logger: logger.With(
+			"package", "service",
+			"component", "Auditor",
+		),
+	}
+}
+
+// Record сохраняет событие в журнале аудита.
+func (a *Auditor) Record(event domain.AuditEvent) {
+	if err := a.repo.Append(&event); err != nil {
+		a.logger.Error("не удалось записать событие аудита",
+			"action", event.Action,
+			"actor_id", event.ActorID,
+			"target_type", event.TargetType,
+			"target_id", event.TargetID,
+			"payload", string(event.Payload),
+			"error", err)
+	}
+}
+
+// userAuditEvent создает событие, выполненное пользователем из запроса с указанными сведениями о клиенте.
+func userAuditEvent(userID int, action domain.AuditAction, meta domain.RequestMeta) domain.AuditEvent {
+	return domain.AuditEvent{
+		ActorType:  domain.AuditActorUser,
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   strconv.Itoa(userID),
+		IP:         meta.IP,
+		UserAgent:  meta.UserAgent,
+	}
+}
+
+// auditPayload сериализует данные события; ошибка сериализации не должна мешать записи события.
+func auditPayload(fields map[string]interface{}) domain.AuditPayload {
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+// AuditService реализует интерфейс domain.AuditService.
+type AuditService struct {
+	repo domain.AuditRepository
+}
+
+// NewAuditService создает новый экземпляр AuditService.
+func NewAuditService(repo domain.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Find возвращает страницу событий аудита и курсор следующей страницы.
+func (s *AuditService) Find(filter domain.AuditFilter) (*domain.AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLimit)
+	pageSize := filter.Limit
+
+	// Запрашиваем на одно событие больше, чтобы узнать, есть ли следующая страница
+	filter.Limit++
+	events, err := s.repo.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.AuditPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextCursor = strconv.FormatInt(page.Events[pageSize-1].ID, 10)
+	}
+	if page.Events == nil {
+		page.Events = []domain.AuditEvent{}
+	}
+
+	return page, nil
+}
+
+// VerifyChain проверяет цепочку хешей журнала аудита.
+func (s *AuditService) VerifyChain() ([]domain.AuditChainViolation, error) {
+	violations, err := s.repo.VerifyChain(maxAuditViolations)
+	if err != nil {
+		return nil, err
+	}
+	if violations == nil {
+		violations = []domain.AuditChainViolation{}
+	}
+	return violations, nil
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"log/slog"
+	"strconv"
 
 	"gophermart/internal/domain"
 	"gophermart/internal/utils"
@@ -10,19 +12,32 @@ import (
 
 var (
 	// ErrInsufficientFunds ошибка недостаточно средств.
-	ErrInsufficientFunds = errors.New("недостаточно средств")
+	ErrInsufficientFunds = domain.ErrInsufficientFunds
+	// ErrWithdrawalNotFound ошибка списание не найдено.
+	ErrWithdrawalNotFound = domain.ErrWithdrawalNotFound
+	// ErrWithdrawalReversed ошибка списание уже было возвращено.
+	ErrWithdrawalReversed = domain.ErrWithdrawalReversed
 )
 
 // BalanceService реализует интерфейс domain.BalanceService.
 type BalanceService struct {
-	repo   domain.BalanceRepository
-	logger *slog.Logger
+	repo    domain.BalanceRepository
+	audit   domain.AuditRecorder
+	metrics domain.BalanceMetrics
+	logger  *slog.Logger
 }
 
 // NewBalanceService создает новый экземпляр BalanceService.
-func NewBalanceService(repo domain.BalanceRepository, logger *slog.Logger) *BalanceService {
+func NewBalanceService(
+	repo domain.BalanceRepository,
+	audit domain.AuditRecorder,
+	metrics domain.BalanceMetrics,
+	logger *slog.Logger,
+) *BalanceService {
 	return &BalanceService{
-		repo: repo,
+		repo:    repo,
+		audit:   audit,
+		metrics: metrics,
 		logger: logger.With(
 			"package", "service",
 			"component", "BalanceService",
@@ -31,38 +46,89 @@ func NewBalanceService(repo domain.BalanceRepository, logger *slog.Logger) *Bala
 }
 
 // GetBalance возвращает текущий баланс пользователя.
-func (s *BalanceService) GetBalance(userID int) (*domain.Balance, error) {
-	return s.repo.GetBalance(userID)
+func (s *BalanceService) GetBalance(ctx context.Context, userID int) (*domain.Balance, error) {
+	return s.repo.GetBalance(ctx, userID)
 }
 
 // Withdraw списывает средства с баланса пользователя.
-func (s *BalanceService) Withdraw(userID int, req *domain.WithdrawalRequest) error {
+func (s *BalanceService) Withdraw(
+	ctx context.Context,
+	userID int,
+	req *domain.WithdrawalRequest,
+	meta domain.RequestMeta,
+) error {
 	// Проверяем номер заказа по алгоритму Луна
 	if !utils.ValidateLuhn(req.Order) {
+		s.metrics.ObserveWithdrawalRejected("invalid_order_number")
 		return domain.ErrInvalidOrderNumber
 	}
 
-	// Получаем текущий баланс
-	balance, err := s.repo.GetBalance(userID)
+	// Проверка баланса и списание выполняются в одной транзакции под блокировкой,
+	// иначе параллельные запросы могут пройти проверку одновременно и увести баланс в минус
+	withdrawal := &domain.Withdrawal{
+		Order: req.Order,
+		Sum:   req.Sum,
+	}
+	err := s.repo.WithBalanceLock(ctx, userID, func(tx domain.BalanceTx) error {
+		// Получаем текущий баланс
+		balance, err := tx.GetBalance(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// Проверяем достаточно ли средств
+		if balance.Current < req.Sum {
+			s.logger.Info("недостаточно средств для списания",
+				"user_id", userID,
+				"текущий баланс", balance.Current,
+				"сумма списания", req.Sum)
+			return ErrInsufficientFunds
+		}
+
+		// Создаем запись о списании
+		return tx.CreateWithdrawal(ctx, userID, withdrawal)
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		s.metrics.ObserveWithdrawalRejected("insufficient_funds")
+	}
 	if err != nil {
 		return err
 	}
 
-	// Проверяем достаточно ли средств
-	if balance.Current < req.Sum {
-		return ErrInsufficientFunds
+	s.metrics.ObserveWithdrawal(withdrawal.Sum)
+
+	event := userAuditEvent(userID, domain.AuditBalanceWithdrawn, meta)
+	event.TargetType = domain.AuditTargetWithdrawal
+	event.TargetID = strconv.Itoa(withdrawal.ID)
+	event.Payload = auditPayload(map[string]interface{}{"order": withdrawal.Order, "sum": withdrawal.Sum})
+	s.audit.Record(event)
+
+	return nil
+}
+
+// GetWithdrawals возвращает страницу списаний пользователя и курсор следующей страницы.
+func (s *BalanceService) GetWithdrawals(
+	ctx context.Context,
+	userID int,
+	filter domain.WithdrawalFilter,
+) (*domain.WithdrawalPage, error) {
+	pageSize := prepareListFilter(&filter.ListFilter)
+	withdrawals, err := s.repo.GetWithdrawals(ctx, userID, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	// Создаем запись о списании
-	withdrawal := &domain.Withdrawal{
-		Order:     req.Order,
-		AmountKop: int64(req.Sum * domain.KopPerRuble),
+	page := &domain.WithdrawalPage{Withdrawals: withdrawals}
+	if pageSize > 0 && len(withdrawals) > pageSize {
+		page.Withdrawals = withdrawals[:pageSize]
+		last := page.Withdrawals[pageSize-1]
+		page.NextCursor = domain.PageCursor{Sort: filter.Sort, Time: last.ProcessedAt, ID: last.ID}.Encode()
 	}
 
-	return s.repo.CreateWithdrawal(userID, withdrawal)
+	return page, nil
 }
 
-// GetWithdrawals возвращает историю списаний пользователя.
-func (s *BalanceService) GetWithdrawals(userID int) ([]domain.Withdrawal, error) {
-	return s.repo.GetWithdrawals(userID)
+// GetHistory возвращает историю операций по балансу пользователя.
+func (s *BalanceService) GetHistory(ctx context.Context, userID int) ([]domain.BalanceOperation, error) {
+	return s.repo.GetHistory(ctx, userID)
 }
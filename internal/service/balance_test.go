@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+)
+
+// migrationsDir каталог миграций относительно пакета service.
+const migrationsDir = "../../migrations"
+
+// nopBalanceMetrics реализация domain.BalanceMetrics, которая ничего не учитывает.
+type nopBalanceMetrics struct{}
+
+func (nopBalanceMetrics) ObserveAccrualCredited(domain.Money) {}
+func (nopBalanceMetrics) ObserveWithdrawal(domain.Money)      {}
+func (nopBalanceMetrics) ObserveWithdrawalRejected(string)    {}
+func (nopBalanceMetrics) ObserveAdjustment(domain.LedgerReferenceType, domain.LedgerDirection, domain.Money) {
+}
+
+// nopAudit реализация domain.AuditRecorder, которая отбрасывает события.
+type nopAudit struct{}
+
+func (nopAudit) Record(domain.AuditEvent) {}
+
+// openTestDB подключается к базе из DATABASE_URI и применяет миграции.
+// Тест пропускается, если переменная не задана.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URI")
+	if dsn == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	goose.SetLogger(goose.NopLogger())
+	if err = goose.SetDialect("postgres"); err != nil {
+		t.Fatalf("failed to set goose dialect: %v", err)
+	}
+	if err = goose.Up(db.DB, migrationsDir); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	return db
+}
+
+// creditUser создает пользователя и начисляет ему amount через обработанный заказ.
+func creditUser(t *testing.T, db *sqlx.DB, logger *slog.Logger, amount domain.Money) int {
+	t.Helper()
+	ctx := context.Background()
+
+	user := &domain.User{
+		Login:        fmt.Sprintf("withdraw-race-%d", time.Now().UnixNano()),
+		PasswordHash: "not-a-hash",
+		Role:         domain.RoleUser,
+	}
+	if err := repository.NewUserRepo(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	orders := repository.NewOrderRepo(db, logger)
+	order := &domain.Order{
+		Number: fmt.Sprintf("%d", time.Now().UnixNano()),
+		UserID: user.ID,
+		Status: domain.OrderStatusNew,
+	}
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if err := orders.ApplyAccrual(ctx, order.ID, domain.OrderStatusProcessed, &amount); err != nil {
+		t.Fatalf("failed to credit accrual: %v", err)
+	}
+
+	return user.ID
+}
+
+func TestWithdrawConcurrent(t *testing.T) {
+	const (
+		workers = 300
+		credit  = 10_000 // 100 рублей
+		sum     = 70     // 70 копеек: на все запросы средств не хватит
+	)
+
+	db := openTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	userID := creditUser(t, db, logger, domain.Money(credit))
+
+	// Запросов больше, чем соединений: часть горутин ждет соединение, как под нагрузкой в сервисе
+	db.SetMaxOpenConns(20)
+	balances := service.NewBalanceService(
+		repository.NewBalanceRepo(db, logger),
+		nopAudit{},
+		nopBalanceMetrics{},
+		logger,
+	)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+		withdrawn atomic.Int64
+		start     = make(chan struct{})
+		errs      = make(chan error, workers)
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			req := &domain.WithdrawalRequest{Order: "2377225624", Sum: domain.Money(sum)}
+			err := balances.Withdraw(context.Background(), userID, req, domain.RequestMeta{})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+				withdrawn.Add(sum)
+			case errors.Is(err, service.ErrInsufficientFunds):
+			default:
+				errs <- err
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected withdraw error: %v", err)
+	}
+
+	balance, err := balances.GetBalance(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+
+	if balance.Current < 0 {
+		t.Errorf("balance went negative: %v", balance.Current)
+	}
+	if withdrawn.Load() > credit {
+		t.Errorf("withdrawn %d kop, credited only %d kop", withdrawn.Load(), credit)
+	}
+	if want := domain.Money(credit - withdrawn.Load()); balance.Current != want {
+		t.Errorf("balance = %v, want %v", balance.Current, want)
+	}
+	if balance.Withdrawn.Kopecks() != withdrawn.Load() {
+		t.Errorf("withdrawn total = %v, want %d kop", balance.Withdrawn, withdrawn.Load())
+	}
+	if want := int64(credit / sum); succeeded.Load() != want {
+		t.Errorf("succeeded = %d withdrawals, want %d", succeeded.Load(), want)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	defaultFailureThreshold  = 5
+	defaultSlowCallThreshold = 5 * time.Second
+	defaultOpenTimeout       = 30 * time.Second
+)
+
+// callOutcome результат запроса с точки зрения автоматического выключателя.
+type callOutcome int
+
+const (
+	// callSuccess система ответила штатно (в том числе 204 и 429).
+	callSuccess callOutcome = iota
+	// callFailure сетевая ошибка, таймаут, ответ 5xx или неверный ответ.
+	callFailure
+	// callIgnored запрос прерван вызывающим и не характеризует состояние системы.
+	callIgnored
+)
+
+// CircuitBreakerConfig содержит пороги автоматического выключателя.
+type CircuitBreakerConfig struct {
+	FailureThreshold  int           // Число ошибок подряд, после которого цепь размыкается
+	SlowCallThreshold time.Duration // Запросы дольше этого времени считаются ошибкой (меньше нуля - не учитывать)
+	OpenTimeout       time.Duration // Время в разомкнутом состоянии до пробного запроса
+}
+
+// circuitBreaker автоматический выключатель с состояниями closed, open и half-open.
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	cfg                 CircuitBreakerConfig
+	state               domain.CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	probeInFlight       bool
+	lastError           string
+	logger              *slog.Logger
+}
+
+// newCircuitBreaker создает выключатель в замкнутом состоянии.
+func newCircuitBreaker(cfg CircuitBreakerConfig, logger *slog.Logger) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.SlowCallThreshold == 0 {
+		cfg.SlowCallThreshold = defaultSlowCallThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultOpenTimeout
+	}
+
+	return &circuitBreaker{
+		cfg:    cfg,
+		state:  domain.CircuitClosed,
+		logger: logger,
+	}
+}
+
+// Allow разрешает запрос или возвращает domain.ErrAccrualCircuitOpen.
+// В полуоткрытом состоянии одновременно выполняется только один пробный запрос.
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case domain.CircuitClosed:
+		return nil
+	case domain.CircuitOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return domain.ErrAccrualCircuitOpen
+		}
+		b.setState(domain.CircuitHalfOpen)
+		b.probeInFlight = true
+		return nil
+	case domain.CircuitHalfOpen:
+		if b.probeInFlight {
+			return domain.ErrAccrualCircuitOpen
+		}
+		b.probeInFlight = true
+		return nil
+	}
+
+	return nil
+}
+
+// Record учитывает результат разрешенного запроса.
+func (b *circuitBreaker) Record(outcome callOutcome, latency time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == domain.CircuitHalfOpen {
+		b.probeInFlight = false
+	}
+
+	if outcome == callSuccess && b.cfg.SlowCallThreshold > 0 && latency > b.cfg.SlowCallThreshold {
+		outcome = callFailure
+		err = errors.New("превышено время ответа " + latency.String())
+	}
+
+	switch outcome {
+	case callIgnored:
+		return
+	case callSuccess:
+		b.consecutiveFailures = 0
+		if b.state != domain.CircuitClosed {
+			b.setState(domain.CircuitClosed)
+		}
+	case callFailure:
+		b.consecutiveFailures++
+		if err != nil {
+			b.lastError = err.Error()
+		}
+		if b.state == domain.CircuitHalfOpen || b.consecutiveFailures >= b.cfg.FailureThreshold {
+			b.openedAt = time.Now()
+			b.setState(domain.CircuitOpen)
+		}
+	}
+}
+
+// Status возвращает снимок состояния выключателя.
+func (b *circuitBreaker) Status() domain.CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := domain.CircuitStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		LastError:           b.lastError,
+	}
+	if b.state != domain.CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+
+	return status
+}
+
+// setState меняет состояние и логирует переход. Вызывается под блокировкой.
+func (b *circuitBreaker) setState(state domain.CircuitState) {
+	previous := b.state
+	b.state = state
+
+	switch state {
+	case domain.CircuitOpen:
+		b.logger.Warn("цепь разомкнута, запросы к системе начислений приостановлены",
+			"предыдущее состояние", previous,
+			"ошибок подряд", b.consecutiveFailures,
+			"последняя ошибка", b.lastError,
+			"таймаут", b.cfg.OpenTimeout)
+	case domain.CircuitHalfOpen:
+		b.logger.Info("цепь полуоткрыта, выполняется пробный запрос к системе начислений")
+	case domain.CircuitClosed:
+		b.logger.Info("цепь замкнута, система начислений доступна",
+			"предыдущее состояние", previous)
+	}
+}
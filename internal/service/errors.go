@@ -1,6 +1,10 @@
 package service
 
-import "errors"
+import (
+	"errors"
+
+	"gophermart/internal/domain"
+)
 
 var (
 	// Ошибки пользователя.
@@ -9,6 +13,15 @@ var (
 	ErrUserExists = errors.New("пользователь уже существует")
 	// ErrInvalidLogin возникает при неверной паре логин/пароль.
 	ErrInvalidLogin = errors.New("неверный логин или пароль")
+	// ErrUserBlocked возникает при входе в заблокированную учетную запись.
+	ErrUserBlocked = domain.ErrUserBlocked
+	// ErrUserNotFound возникает, если пользователь с указанным идентификатором не существует.
+	ErrUserNotFound = errors.New("пользователь не найден")
+	// ErrInvalidPassword возникает при неверном текущем пароле во время смены пароля.
+	ErrInvalidPassword = errors.New("неверный текущий пароль")
+	// ErrInvalidRefreshToken возникает при предъявлении неизвестного, истекшего, отозванного
+	// или уже использованного refresh-токена.
+	ErrInvalidRefreshToken = errors.New("недействительный refresh-токен")
 
 	// Ошибки заказов.
 
@@ -21,4 +34,6 @@ var (
 	ErrInvalidOrderNumber = errors.New(
 		"неверный формат номера заказа, номер заказа должен состоять из 10 цифр и проходить по алгоритму Луна",
 	)
+	// ErrOrderNotFound возникает, если заказ не существует или принадлежит другому пользователю.
+	ErrOrderNotFound = errors.New("заказ не найден")
 )
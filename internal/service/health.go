@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// defaultHealthCheckTimeout ограничивает время каждой проверки готовности.
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthService реализует интерфейс domain.HealthService.
+type HealthService struct {
+	checks       []domain.HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+	logger       *slog.Logger
+}
+
+// NewHealthService создает новый экземпляр HealthService.
+// Нулевой timeout заменяется значением по умолчанию.
+func NewHealthService(checks []domain.HealthCheck, timeout time.Duration, logger *slog.Logger) *HealthService {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	return &HealthService{
+		checks:  checks,
+		timeout: timeout,
+		logger: logger.With(
+			"package", "service",
+			"component", "HealthService",
+		),
+	}
+}
+
+// StartShutdown переводит сервис в состояние остановки: проверка готовности больше не проходит,
+// и балансировщик перестает направлять новые запросы.
+func (s *HealthService) StartShutdown() {
+	if !s.shuttingDown.Swap(true) {
+		s.logger.Info("начата остановка, сервис больше не готов принимать запросы")
+	}
+}
+
+// Liveness сообщает, что процесс работает и обрабатывает запросы.
+func (s *HealthService) Liveness() *domain.HealthReport {
+	return &domain.HealthReport{Status: domain.HealthUp}
+}
+
+// Readiness выполняет проверки зависимостей параллельно, каждую со своим таймаутом.
+func (s *HealthService) Readiness(ctx context.Context) *domain.HealthReport {
+	if s.shuttingDown.Load() {
+		return &domain.HealthReport{Status: domain.HealthDown, ShuttingDown: true}
+	}
+
+	report := &domain.HealthReport{
+		Status: domain.HealthUp,
+		Checks: make(map[string]domain.HealthCheckResult, len(s.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range s.checks {
+		wg.Add(1)
+		go func(check domain.HealthCheck) {
+			defer wg.Done()
+			result := s.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != domain.HealthUp {
+				report.Status = domain.HealthDown
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run выполняет проверку с таймаутом и измеряет ее длительность.
+func (s *HealthService) run(ctx context.Context, check domain.HealthCheck) domain.HealthCheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(checkCtx)
+	result := domain.HealthCheckResult{
+		Status:    domain.HealthUp,
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		s.logger.Warn("проверка готовности не пройдена", "check", check.Name, "error", err)
+		result.Status = domain.HealthDown
+	}
+
+	return result
+}
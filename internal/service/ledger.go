@@ -0,0 +1,46 @@
+package service
+
+import (
+	"log/slog"
+
+	"gophermart/internal/domain"
+)
+
+// LedgerService проверяет согласованность журнала проводок с исходными данными.
+type LedgerService struct {
+	repo   domain.LedgerRepository
+	logger *slog.Logger
+}
+
+// NewLedgerService создает новый экземпляр LedgerService.
+func NewLedgerService(repo domain.LedgerRepository, logger *slog.Logger) *LedgerService {
+	return &LedgerService{
+		repo: repo,
+		logger: logger.With(
+			"package", "service",
+			"component", "LedgerService",
+		),
+	}
+}
+
+// CheckConsistency возвращает найденные расхождения и логирует каждое из них.
+func (s *LedgerService) CheckConsistency() ([]domain.LedgerDiscrepancy, error) {
+	discrepancies, err := s.repo.FindDiscrepancies()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range discrepancies {
+		s.logger.Warn("расхождение в журнале проводок",
+			"user_id", d.UserID,
+			"проверка", d.Check,
+			"ожидалось (коп)", d.Expected,
+			"фактически (коп)", d.Actual)
+	}
+
+	if len(discrepancies) == 0 {
+		s.logger.Info("журнал проводок согласован с исходными данными")
+	}
+
+	return discrepancies, nil
+}
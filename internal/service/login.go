@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	defaultLoginMaxFailures   = 5
+	defaultLoginIPMaxFailures = 20
+	defaultLoginLockout       = 1 * time.Minute
+	defaultLoginMaxLockout    = 1 * time.Hour
+	// maxLockoutShift ограничивает показатель степени, чтобы сдвиг не переполнил time.Duration.
+	maxLockoutShift = 30
+)
+
+// LoginThrottleConfig содержит пороги блокировки входа. Нулевые значения заменяются значениями по умолчанию.
+type LoginThrottleConfig struct {
+	MaxFailures   int           // Неудачных входов в учетную запись до первой блокировки
+	IPMaxFailures int           // Неудачных входов с одного IP-адреса до первой блокировки
+	Lockout       time.Duration // Длительность первой блокировки; каждая следующая вдвое дольше
+	MaxLockout    time.Duration // Максимальная длительность блокировки; за это время без неудач счетчик сбрасывается
+}
+
+// withDefaults возвращает копию конфигурации с заполненными значениями по умолчанию.
+func (c LoginThrottleConfig) withDefaults() LoginThrottleConfig {
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = defaultLoginMaxFailures
+	}
+	if c.IPMaxFailures <= 0 {
+		c.IPMaxFailures = defaultLoginIPMaxFailures
+	}
+	if c.Lockout <= 0 {
+		c.Lockout = defaultLoginLockout
+	}
+	if c.MaxLockout < c.Lockout {
+		c.MaxLockout = max(defaultLoginMaxLockout, c.Lockout)
+	}
+	return c
+}
+
+// LoginLockedError ошибка вход временно заблокирован после серии неудачных попыток.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("вход временно заблокирован, повторить через %v", e.RetryAfter)
+}
+
+// loginThrottler учитывает неудачные входы по логину и по IP-адресу и назначает блокировки,
+// длительность которых растет с каждой следующей неудачей.
+type loginThrottler struct {
+	repo   domain.LoginThrottleRepository
+	config LoginThrottleConfig
+	logger *slog.Logger
+}
+
+// newLoginThrottler создает новый экземпляр loginThrottler.
+func newLoginThrottler(repo domain.LoginThrottleRepository, cfg LoginThrottleConfig) *loginThrottler {
+	return &loginThrottler{
+		repo:   repo,
+		config: cfg.withDefaults(),
+		logger: slog.Default().With(
+			"package", "service",
+			"component", "loginThrottler",
+		),
+	}
+}
+
+// check возвращает *LoginLockedError, если вход для логина или IP-адреса заблокирован.
+func (t *loginThrottler) check(login, ip string) error {
+	throttles, err := t.repo.Find(login, ip)
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil {
+			retryAfter = max(retryAfter, time.Until(*throttle.LockedUntil))
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// fail учитывает неудачный вход. Возвращает *LoginLockedError, если неудача привела к блокировке.
+// Ошибка учета по логину не мешает учесть неудачу по IP-адресу, иначе перебор с такими логинами
+// не приближал бы блокировку адреса.
+func (t *loginThrottler) fail(login, ip string) error {
+	var (
+		retryAfter time.Duration
+		errs       []error
+	)
+
+	for _, target := range []struct {
+		scope       domain.LoginThrottleScope
+		key         string
+		maxFailures int
+	}{
+		{domain.LoginThrottleByLogin, login, t.config.MaxFailures},
+		{domain.LoginThrottleByIP, ip, t.config.IPMaxFailures},
+	} {
+		if target.key == "" {
+			continue
+		}
+
+		throttle, err := t.repo.RecordFailure(target.scope, target.key, t.config.MaxLockout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to record %s failure: %w", target.scope, err))
+			continue
+		}
+
+		lockout := t.lockout(throttle.Failures, target.maxFailures)
+		if lockout <= 0 {
+			continue
+		}
+
+		if lockErr := t.repo.Lock(target.scope, target.key, time.Now().Add(lockout)); lockErr != nil {
+			errs = append(errs, fmt.Errorf("failed to lock %s: %w", target.scope, lockErr))
+			continue
+		}
+		t.logger.Warn("вход заблокирован после неудачных попыток",
+			"scope", target.scope,
+			"key", target.key,
+			"неудач", throttle.Failures,
+			"блокировка", lockout)
+		retryAfter = max(retryAfter, lockout)
+	}
+
+	if retryAfter > 0 {
+		if len(errs) > 0 {
+			t.logger.Error("не удалось учесть неудачный вход", "error", errors.Join(errs...))
+		}
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return errors.Join(errs...)
+}
+
+// succeed сбрасывает счетчик учетной записи после успешного входа.
+// Счетчик IP-адреса не сбрасывается, чтобы вход в собственную учетную запись
+// не позволял продолжать перебор чужих.
+func (t *loginThrottler) succeed(login string) error {
+	return t.repo.Reset(domain.LoginThrottleByLogin, login)
+}
+
+// lockout возвращает длительность блокировки после failures неудач или 0, если порог не достигнут.
+func (t *loginThrottler) lockout(failures, maxFailures int) time.Duration {
+	if failures < maxFailures {
+		return 0
+	}
+
+	shift := min(failures-maxFailures, maxLockoutShift)
+	lockout := t.config.Lockout << shift
+	if lockout <= 0 || lockout > t.config.MaxLockout {
+		lockout = t.config.MaxLockout
+	}
+	return lockout
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// memThrottleRepo счетчики неудачных входов в памяти. Для логинов длиннее maxKey
+// RecordFailure возвращает ошибку, как колонка ограниченной длины.
+type memThrottleRepo struct {
+	failures map[domain.LoginThrottleScope]map[string]int
+	locked   map[domain.LoginThrottleScope]map[string]time.Time
+	maxKey   int
+}
+
+func newMemThrottleRepo(maxKey int) *memThrottleRepo {
+	return &memThrottleRepo{
+		failures: map[domain.LoginThrottleScope]map[string]int{
+			domain.LoginThrottleByLogin: {},
+			domain.LoginThrottleByIP:    {},
+		},
+		locked: map[domain.LoginThrottleScope]map[string]time.Time{
+			domain.LoginThrottleByLogin: {},
+			domain.LoginThrottleByIP:    {},
+		},
+		maxKey: maxKey,
+	}
+}
+
+func (r *memThrottleRepo) Find(string, string) ([]domain.LoginThrottle, error) {
+	return nil, nil
+}
+
+func (r *memThrottleRepo) RecordFailure(
+	scope domain.LoginThrottleScope,
+	key string,
+	_ time.Duration,
+) (*domain.LoginThrottle, error) {
+	if len(key) > r.maxKey {
+		return nil, errors.New("value too long for type character varying(255)")
+	}
+	r.failures[scope][key]++
+	return &domain.LoginThrottle{Scope: scope, Key: key, Failures: r.failures[scope][key]}, nil
+}
+
+func (r *memThrottleRepo) Lock(scope domain.LoginThrottleScope, key string, until time.Time) error {
+	r.locked[scope][key] = until
+	return nil
+}
+
+func (r *memThrottleRepo) Reset(scope domain.LoginThrottleScope, key string) error {
+	delete(r.failures[scope], key)
+	return nil
+}
+
+func TestLoginThrottlerFailCountsIPWhenLoginFails(t *testing.T) {
+	const ip = "203.0.113.7"
+	repo := newMemThrottleRepo(255)
+	throttler := newLoginThrottler(repo, LoginThrottleConfig{IPMaxFailures: 3})
+	login := strings.Repeat("a", 300)
+
+	for i := 1; i < 3; i++ {
+		err := throttler.fail(login, ip)
+		if err == nil {
+			t.Fatalf("attempt %d: fail() = nil, want login scope error", i)
+		}
+		if got := repo.failures[domain.LoginThrottleByIP][ip]; got != i {
+			t.Fatalf("attempt %d: ip failures = %d, want %d", i, got, i)
+		}
+	}
+
+	// Третья неудача с адреса блокирует его, несмотря на ошибку учета по логину
+	var lockedErr *LoginLockedError
+	if err := throttler.fail(login, ip); !errors.As(err, &lockedErr) {
+		t.Fatalf("fail() = %v, want *LoginLockedError", err)
+	}
+	if _, ok := repo.locked[domain.LoginThrottleByIP][ip]; !ok {
+		t.Error("ip was not locked")
+	}
+}
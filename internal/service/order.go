@@ -1,28 +1,32 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log/slog"
+	"strconv"
 
 	"gophermart/internal/domain"
+	"gophermart/internal/tracing"
 	"gophermart/internal/utils"
 )
 
 // OrderService реализует интерфейс domain.OrderService.
 type OrderService struct {
-	repo domain.OrderRepository
+	repo  domain.OrderRepository
+	audit domain.AuditRecorder
 }
 
 // NewOrderService создает новый экземпляр OrderService.
-func NewOrderService(repo domain.OrderRepository) *OrderService {
-	return &OrderService{repo: repo}
+func NewOrderService(repo domain.OrderRepository, audit domain.AuditRecorder) *OrderService {
+	return &OrderService{repo: repo, audit: audit}
 }
 
 // Register регистрирует новый заказ для пользователя.
-func (s *OrderService) Register(userID int, number string) error {
+func (s *OrderService) Register(ctx context.Context, userID int, number string, meta domain.RequestMeta) error {
 	// Проверяем, существует ли заказ
-	existingOrder, err := s.repo.FindByNumber(number)
+	existingOrder, err := s.repo.FindByNumber(ctx, number)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
@@ -48,8 +52,14 @@ func (s *OrderService) Register(userID int, number string) error {
 		UserID: userID,
 		Status: domain.OrderStatusNew,
 	}
+	// Воркер свяжет обработку заказа с трассировкой запроса, в котором заказ был загружен
+	if traceParent := tracing.TraceParent(ctx); traceParent != "" {
+		order.TraceParent = &traceParent
+	}
 
-	err = s.repo.Create(order)
+	if err = s.repo.Create(ctx, order); err != nil {
+		return err
+	}
 
 	slog.Info("created new order",
 		"id", order.ID,
@@ -58,20 +68,60 @@ func (s *OrderService) Register(userID int, number string) error {
 		"order_number", number,
 		"status", domain.OrderStatusNew)
 
-	return err
+	event := userAuditEvent(userID, domain.AuditOrderUploaded, meta)
+	event.TargetType = domain.AuditTargetOrder
+	event.TargetID = strconv.Itoa(order.ID)
+	event.Payload = auditPayload(map[string]interface{}{"number": number})
+	s.audit.Record(event)
+
+	return nil
+}
+
+// GetOrders возвращает страницу заказов пользователя и курсор следующей страницы.
+func (s *OrderService) GetOrders(
+	ctx context.Context,
+	userID int,
+	filter domain.OrderFilter,
+) (*domain.OrderPage, error) {
+	pageSize := prepareListFilter(&filter.ListFilter)
+	orders, err := s.repo.FindByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.OrderPage{Orders: orders}
+	if pageSize > 0 && len(orders) > pageSize {
+		page.Orders = orders[:pageSize]
+		last := page.Orders[pageSize-1]
+		page.NextCursor = domain.PageCursor{Sort: filter.Sort, Time: last.UploadedAt, ID: last.ID}.Encode()
+	}
+
+	return page, nil
 }
 
-// GetOrders возвращает список заказов пользователя.
-func (s *OrderService) GetOrders(userID int) ([]domain.Order, error) {
-	orders, err := s.repo.FindByUserID(userID)
+// GetOrder возвращает заказ пользователя вместе с историей обработки.
+// Чужой заказ неотличим от несуществующего, чтобы по ответу нельзя было узнать, что номер уже загружен.
+func (s *OrderService) GetOrder(ctx context.Context, userID int, number string) (*domain.OrderDetails, error) {
+	order, err := s.repo.FindByNumber(ctx, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	if order.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
 
-	// Вычисляем сумму в рублях для каждого заказа
-	for i := range orders {
-		orders[i].CalculateAccrualRub()
+	history, err := s.repo.FindStatusHistory(ctx, order.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	return orders, nil
+	return &domain.OrderDetails{
+		Order:           *order,
+		AccrualRequests: order.Attempts,
+		FailureReason:   order.FailureReason,
+		History:         history,
+	}, nil
 }
@@ -0,0 +1,31 @@
+package service
+
+import "gophermart/internal/domain"
+
+const (
+	// defaultListLimit размер страницы по умолчанию, если клиент передал курсор без limit.
+	defaultListLimit = 500
+	maxListLimit     = 1000
+)
+
+// prepareListFilter ограничивает размер страницы и возвращает его.
+// Без limit и курсора список не делится на страницы, и возвращается 0: клиенты без поддержки
+// курсора получают все записи. Иначе в filter запрашивается на одну запись больше,
+// чтобы узнать, есть ли следующая страница.
+func prepareListFilter(filter *domain.ListFilter) int {
+	if filter.Sort == "" {
+		filter.Sort = domain.SortNewestFirst
+	}
+	if filter.Limit <= 0 && filter.After == nil {
+		filter.Limit = 0
+		return 0
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	filter.Limit = min(filter.Limit, maxListLimit)
+	pageSize := filter.Limit
+	filter.Limit++
+	return pageSize
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordMinLength = 6
+	// defaultPasswordMaxLength ограничивает длину пароля: bcrypt учитывает только первые 72 байта.
+	defaultPasswordMaxLength = 72
+)
+
+// commonPasswords самые распространенные пароли, которые отклоняются при любой политике.
+//
+//nolint:gochecknoglobals // неизменяемый справочник
+var commonPasswords = []string{
+	"123456", "1234567", "12345678", "123456789", "1234567890", "111111", "000000", "123123",
+	"654321", "666666", "121212", "password", "password1", "qwerty", "qwerty123", "qwertyuiop",
+	"abc123", "iloveyou", "admin", "welcome", "letmein", "monkey", "dragon", "football",
+	"baseball", "sunshine", "princess", "master", "passw0rd", "zaq12wsx", "1q2w3e4r", "йцукен",
+}
+
+// PasswordPolicyConfig содержит требования к паролю. Нулевые значения заменяются значениями по умолчанию.
+type PasswordPolicyConfig struct {
+	MinLength     int    // Минимальная длина в символах
+	MaxLength     int    // Максимальная длина в байтах
+	MinClasses    int    // Сколько классов символов (строчные, заглавные, цифры, прочие) должно встретиться
+	BlocklistFile string // Файл с дополнительными запрещенными паролями, по одному в строке
+}
+
+// PasswordPolicyError ошибка пароль не соответствует политике.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "пароль не соответствует требованиям: " + strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy проверяет пароли на соответствие требованиям.
+type PasswordPolicy struct {
+	config    PasswordPolicyConfig
+	blocklist map[string]struct{}
+}
+
+// NewPasswordPolicy создает новый экземпляр PasswordPolicy и загружает список запрещенных паролей.
+func NewPasswordPolicy(cfg PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = defaultPasswordMinLength
+	}
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = defaultPasswordMaxLength
+	}
+
+	policy := &PasswordPolicy{
+		config:    cfg,
+		blocklist: make(map[string]struct{}, len(commonPasswords)),
+	}
+	for _, password := range commonPasswords {
+		policy.blocklist[password] = struct{}{}
+	}
+
+	if cfg.BlocklistFile != "" {
+		if err := policy.loadBlocklist(cfg.BlocklistFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// loadBlocklist добавляет пароли из файла в список запрещенных.
+func (p *PasswordPolicy) loadBlocklist(path string) error {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return fmt.Errorf("failed to open password blocklist: %w", openErr)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			p.blocklist[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read password blocklist: %w", err)
+	}
+
+	return nil
+}
+
+// Validate возвращает *PasswordPolicyError со всеми нарушениями или nil.
+func (p *PasswordPolicy) Validate(login, password string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.config.MinLength {
+		violations = append(violations, fmt.Sprintf("не короче %d символов", p.config.MinLength))
+	}
+	if len(password) > p.config.MaxLength {
+		violations = append(violations, fmt.Sprintf("не длиннее %d байт", p.config.MaxLength))
+	}
+	if classes := characterClasses(password); classes < p.config.MinClasses {
+		violations = append(violations, fmt.Sprintf(
+			"не менее %d из классов символов: строчные, заглавные, цифры, прочие", p.config.MinClasses))
+	}
+	if _, blocked := p.blocklist[strings.ToLower(password)]; blocked {
+		violations = append(violations, "слишком распространенный пароль")
+	}
+	if login != "" && strings.EqualFold(password, login) {
+		violations = append(violations, "не должен совпадать с логином")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// characterClasses возвращает количество классов символов, встречающихся в пароле.
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// defaultRetryAfter пауза после 429, если система начислений не прислала Retry-After.
+	defaultRetryAfter = 60 * time.Second
+	secondsPerMinute  = 60
+)
+
+// rateLimitHintPattern соответствует подсказке из тела ответа 429 системы начислений.
+var rateLimitHintPattern = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// rateLimiter общий для всех воркеров ограничитель запросов к системе начислений.
+// Сочетает token bucket для равномерного темпа запросов и общую паузу после ответа 429.
+type rateLimiter struct {
+	mu          sync.Mutex
+	limiter     *rate.Limiter
+	pausedUntil time.Time
+	logger      *slog.Logger
+}
+
+// newRateLimiter создает ограничитель с заданным темпом запросов в секунду (0 - без ограничения).
+func newRateLimiter(requestsPerSecond float64, logger *slog.Logger) *rateLimiter {
+	limit := rate.Inf
+	if requestsPerSecond > 0 {
+		limit = rate.Limit(requestsPerSecond)
+	}
+
+	return &rateLimiter{
+		limiter: rate.NewLimiter(limit, 1),
+		logger:  logger,
+	}
+}
+
+// Wait блокирует вызывающего до окончания общей паузы и получения токена.
+// Возвращает ошибку контекста, если он отменен во время ожидания.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		wait := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+
+		if wait <= 0 {
+			break
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// Пауза могла быть продлена, пока мы ждали, поэтому проверяем заново
+		}
+	}
+
+	return l.limiter.Wait(ctx)
+}
+
+// Pause приостанавливает запросы всех вызывающих на указанное время.
+func (l *rateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		l.logger.Info("запросы к системе начислений приостановлены", "пауза", d)
+	}
+}
+
+// AdaptToLimit снижает темп запросов до лимита, сообщенного системой начислений.
+// Если настроенный темп уже ниже, он не меняется.
+func (l *rateLimiter) AdaptToLimit(requestsPerMinute int) {
+	if requestsPerMinute <= 0 {
+		return
+	}
+
+	limit := rate.Limit(float64(requestsPerMinute) / secondsPerMinute)
+	if limit >= l.limiter.Limit() {
+		return
+	}
+
+	l.limiter.SetLimit(limit)
+	l.logger.Info("темп запросов к системе начислений снижен",
+		"запросов в минуту", requestsPerMinute)
+}
+
+// parseRetryAfter разбирает заголовок Retry-After в секундах или в формате HTTP-даты.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return defaultRetryAfter
+}
+
+// parseRateLimitHint извлекает лимит запросов в минуту из заголовка X-RateLimit-Limit
+// или из тела ответа вида "No more than N requests per minute allowed".
+func parseRateLimitHint(header http.Header, body []byte) int {
+	if value := header.Get("X-RateLimit-Limit"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+
+	if match := rateLimitHintPattern.FindSubmatch(body); match != nil {
+		if n, err := strconv.Atoi(string(match[1])); err == nil {
+			return n
+		}
+	}
+
+	return 0
+}
@@ -1,57 +1,161 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 
+	"gophermart/internal/auth"
 	"gophermart/internal/domain"
 )
 
+// refreshTokenBytes длина случайной части refresh-токена.
+const refreshTokenBytes = 32
+
+// UserConfig содержит настройки выдачи токенов и защиты входа.
+type UserConfig struct {
+	AccessTTL     time.Duration       // Время жизни access-токена
+	RefreshTTL    time.Duration       // Время жизни refresh-токена
+	LoginThrottle LoginThrottleConfig // Пороги блокировки входа после неудачных попыток
+}
+
 // UserService реализует интерфейс domain.UserService.
 type UserService struct {
-	repo           domain.UserRepository
-	jwtSecret      []byte
-	jwtExpiryHours int
+	repo       domain.UserRepository
+	sessions   domain.SessionRepository
+	throttler  *loginThrottler
+	signer     auth.Signer
+	hasher     domain.PasswordHasher
+	policy     *PasswordPolicy
+	audit      domain.AuditRecorder
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	logger     *slog.Logger
+
+	// dummyHash хеш случайного пароля для проверки при неизвестном логине,
+	// чтобы время ответа не выдавало существование учетной записи
+	dummyHash string
 }
 
 // NewUserService создает новый экземпляр UserService.
-func NewUserService(repo domain.UserRepository, jwtSecret string, jwtExpirationTime time.Duration) *UserService {
+func NewUserService(
+	repo domain.UserRepository,
+	sessions domain.SessionRepository,
+	throttles domain.LoginThrottleRepository,
+	signer auth.Signer,
+	hasher domain.PasswordHasher,
+	policy *PasswordPolicy,
+	audit domain.AuditRecorder,
+	cfg UserConfig,
+) *UserService {
 	return &UserService{
-		repo:           repo,
-		jwtSecret:      []byte(jwtSecret),
-		jwtExpiryHours: int(jwtExpirationTime.Hours()),
+		repo:       repo,
+		sessions:   sessions,
+		throttler:  newLoginThrottler(throttles, cfg.LoginThrottle),
+		signer:     signer,
+		hasher:     hasher,
+		policy:     policy,
+		audit:      audit,
+		accessTTL:  cfg.AccessTTL,
+		refreshTTL: cfg.RefreshTTL,
+		logger: slog.Default().With(
+			"package", "service",
+			"component", "UserService",
+		),
+		dummyHash: newDummyHash(hasher),
 	}
 }
 
-// generateToken создает новый JWT токен для пользователя.
-func (s *UserService) generateToken(userID int, login string) (*domain.AuthToken, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"login":   login,
-		"exp":     time.Now().Add(time.Duration(s.jwtExpiryHours) * time.Hour).Unix(),
+// generateToken создает новый JWT токен для пользователя в рамках сессии.
+// Токен подписывается текущим ключом набора, его kid попадает в заголовок.
+func (s *UserService) generateToken(user *domain.User, sessionID int64) (string, error) {
+	role := user.Role
+	if role == "" {
+		role = domain.RoleUser
+	}
+
+	now := time.Now()
+	return s.signer.Sign(jwt.MapClaims{
+		"user_id": user.ID,
+		"login":   user.Login,
+		"role":    role,
+		"sid":     sessionID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTTL).Unix(),
 	})
+}
+
+// generateRefreshToken создает случайный refresh-токен и его хеш для хранения.
+func generateRefreshToken() (string, string, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashRefreshToken(token), nil
+}
 
-	tokenString, err := token.SignedString(s.jwtSecret)
-	if err != nil {
-		return nil, err
+// hashRefreshToken вычисляет хеш refresh-токена, под которым он хранится в базе.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens открывает новую сессию и выдает для нее пару токенов.
+func (s *UserService) issueTokens(user *domain.User) (*domain.AuthToken, error) {
+	refreshToken, refreshHash, genErr := generateRefreshToken()
+	if genErr != nil {
+		return nil, genErr
+	}
+
+	session, createErr := s.sessions.Create(user.ID, refreshHash, time.Now().Add(s.refreshTTL))
+	if createErr != nil {
+		return nil, fmt.Errorf("failed to create session: %w", createErr)
+	}
+
+	accessToken, signErr := s.generateToken(user, session.ID)
+	if signErr != nil {
+		return nil, signErr
 	}
 
-	return &domain.AuthToken{Token: tokenString}, nil
+	return &domain.AuthToken{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
 }
 
 // Register создает нового пользователя с указанными учетными данными.
-func (s *UserService) Register(login, password string) (*domain.AuthToken, error) {
+func (s *UserService) Register(
+	ctx context.Context,
+	login,
+	password string,
+	meta domain.RequestMeta,
+) (*domain.AuthToken, error) {
 	// Проверяем, существует ли пользователь
-	existingUser, findErr := s.repo.FindByLogin(login)
+	existingUser, findErr := s.repo.FindByLogin(ctx, login)
 	if findErr == nil && existingUser != nil {
 		return nil, ErrUserExists
 	}
 
+	// Проверяем пароль на соответствие политике
+	if policyErr := s.policy.Validate(login, password); policyErr != nil {
+		return nil, policyErr
+	}
+
 	// Хешируем пароль
-	hashedPassword, hashErr := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, hashErr := s.hasher.Hash(password)
 	if hashErr != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", hashErr)
 	}
@@ -59,16 +163,20 @@ func (s *UserService) Register(login, password string) (*domain.AuthToken, error
 	// Создаем нового пользователя
 	user := &domain.User{
 		Login:        login,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
 	// Сохраняем пользователя в базу
-	if createErr := s.repo.Create(user); createErr != nil {
+	if createErr := s.repo.Create(ctx, user); createErr != nil {
 		return nil, fmt.Errorf("failed to create user: %w", createErr)
 	}
 
-	// Генерируем JWT токен
-	token, tokenErr := s.generateToken(user.ID, user.Login)
+	event := userAuditEvent(user.ID, domain.AuditUserRegistered, meta)
+	event.Payload = auditPayload(map[string]interface{}{"login": login})
+	s.audit.Record(event)
+
+	// Открываем сессию и генерируем токены
+	token, tokenErr := s.issueTokens(user)
 	if tokenErr != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", tokenErr)
 	}
@@ -77,23 +185,253 @@ func (s *UserService) Register(login, password string) (*domain.AuthToken, error
 }
 
 // Authenticate проверяет учетные данные пользователя и возвращает токен, если данные верны.
-func (s *UserService) Authenticate(login, password string) (*domain.AuthToken, error) {
+// После серии неудачных попыток для логина или IP-адреса клиента возвращает *LoginLockedError.
+func (s *UserService) Authenticate(
+	ctx context.Context,
+	login,
+	password string,
+	meta domain.RequestMeta,
+) (*domain.AuthToken, error) {
+	if lockErr := s.throttler.check(login, meta.IP); lockErr != nil {
+		var lockedErr *LoginLockedError
+		if errors.As(lockErr, &lockedErr) {
+			s.recordLoginFailure(nil, login, "locked", meta)
+		}
+		return nil, lockErr
+	}
+
 	// Ищем пользователя по логину
-	user, findErr := s.repo.FindByLogin(login)
+	user, findErr := s.repo.FindByLogin(ctx, login)
 	if findErr != nil {
-		return nil, ErrInvalidLogin
+		if !errors.Is(findErr, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to find user: %w", findErr)
+		}
+		// Выполняем такую же проверку пароля, как для существующего пользователя
+		_, _ = s.hasher.Verify(s.dummyHash, password)
+		return nil, s.loginFailed(nil, login, meta)
 	}
 
 	// Проверяем пароль
-	if compareErr := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); compareErr != nil {
-		return nil, ErrInvalidLogin
+	valid, verifyErr := s.hasher.Verify(user.PasswordHash, password)
+	if verifyErr != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", verifyErr)
+	}
+	if !valid {
+		return nil, s.loginFailed(&user.ID, login, meta)
+	}
+
+	// О блокировке сообщаем только после проверки пароля, чтобы не раскрывать ее перебором
+	if user.Blocked() {
+		s.recordLoginFailure(&user.ID, login, "blocked", meta)
+		return nil, ErrUserBlocked
+	}
+
+	if resetErr := s.throttler.succeed(login); resetErr != nil {
+		return nil, fmt.Errorf("failed to reset login throttle: %w", resetErr)
+	}
+
+	// Пароль известен только сейчас, поэтому устаревший хеш пересчитываем при входе
+	s.rehashIfNeeded(ctx, user, password)
+
+	// Открываем сессию и генерируем токены
+	token, tokenErr := s.issueTokens(user)
+	if tokenErr != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", tokenErr)
+	}
+
+	event := userAuditEvent(user.ID, domain.AuditUserLogin, meta)
+	event.Payload = auditPayload(map[string]interface{}{"login": login})
+	s.audit.Record(event)
+
+	return token, nil
+}
+
+// loginFailed учитывает неудачный вход и возвращает ошибку для клиента.
+// userID известен, только если логин существует.
+func (s *UserService) loginFailed(userID *int, login string, meta domain.RequestMeta) error {
+	if failErr := s.throttler.fail(login, meta.IP); failErr != nil {
+		var lockedErr *LoginLockedError
+		if errors.As(failErr, &lockedErr) {
+			s.recordLoginFailure(userID, login, "locked", meta)
+			return lockedErr
+		}
+		return fmt.Errorf("failed to record login failure: %w", failErr)
+	}
+	s.recordLoginFailure(userID, login, "invalid_credentials", meta)
+	return ErrInvalidLogin
+}
+
+// recordLoginFailure записывает неудачный вход в журнал аудита.
+// Действие выполняет неаутентифицированный клиент, поэтому пользователь указывается только как объект.
+func (s *UserService) recordLoginFailure(userID *int, login, reason string, meta domain.RequestMeta) {
+	event := domain.AuditEvent{
+		ActorType: domain.AuditActorAnonymous,
+		Action:    domain.AuditUserLoginFailed,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		Payload:   auditPayload(map[string]interface{}{"login": login, "reason": reason}),
+	}
+	if userID != nil {
+		event.TargetType = domain.AuditTargetUser
+		event.TargetID = strconv.Itoa(*userID)
+	}
+	s.audit.Record(event)
+}
+
+// newDummyHash вычисляет хеш случайного пароля тем же алгоритмом, что и настоящие пароли.
+func newDummyHash(hasher domain.PasswordHasher) string {
+	password := make([]byte, refreshTokenBytes)
+	_, _ = rand.Read(password)
+	hash, _ := hasher.Hash(base64.RawURLEncoding.EncodeToString(password))
+	return hash
+}
+
+// rehashIfNeeded пересчитывает хеш пароля текущим алгоритмом. Ошибки не мешают входу.
+func (s *UserService) rehashIfNeeded(ctx context.Context, user *domain.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, hashErr := s.hasher.Hash(password)
+	if hashErr != nil {
+		s.logger.Error("не удалось пересчитать хеш пароля", "user_id", user.ID, "error", hashErr)
+		return
+	}
+	if updateErr := s.repo.UpdatePassword(ctx, user.ID, hash); updateErr != nil {
+		s.logger.Error("не удалось сохранить пересчитанный хеш пароля", "user_id", user.ID, "error", updateErr)
+		return
+	}
+	s.logger.Info("хеш пароля пересчитан текущим алгоритмом", "user_id", user.ID)
+}
+
+// ChangePassword меняет пароль после проверки текущего, отзывает все сессии пользователя
+// и открывает новую сессию для клиента, сменившего пароль.
+func (s *UserService) ChangePassword(
+	ctx context.Context,
+	userID int,
+	oldPassword, newPassword string,
+	meta domain.RequestMeta,
+) (*domain.AuthToken, error) {
+	user, findErr := s.repo.FindByID(ctx, userID)
+	if findErr != nil {
+		return nil, fmt.Errorf("failed to find user: %w", findErr)
+	}
+
+	// Подбор текущего пароля ограничивается тем же счетчиком, что и вход
+	if lockErr := s.throttler.check(user.Login, meta.IP); lockErr != nil {
+		return nil, lockErr
+	}
+
+	valid, verifyErr := s.hasher.Verify(user.PasswordHash, oldPassword)
+	if verifyErr != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", verifyErr)
+	}
+	if !valid {
+		if failErr := s.throttler.fail(user.Login, meta.IP); failErr != nil {
+			var lockedErr *LoginLockedError
+			if errors.As(failErr, &lockedErr) {
+				return nil, lockedErr
+			}
+			return nil, fmt.Errorf("failed to record password failure: %w", failErr)
+		}
+		return nil, ErrInvalidPassword
+	}
+	if resetErr := s.throttler.succeed(user.Login); resetErr != nil {
+		return nil, fmt.Errorf("failed to reset login throttle: %w", resetErr)
+	}
+
+	if policyErr := s.policy.Validate(user.Login, newPassword); policyErr != nil {
+		return nil, policyErr
+	}
+
+	hash, hashErr := s.hasher.Hash(newPassword)
+	if hashErr != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", hashErr)
+	}
+	if changeErr := s.repo.ChangePassword(ctx, user.ID, hash, domain.SessionRevokePasswordChange); changeErr != nil {
+		return nil, fmt.Errorf("failed to change password: %w", changeErr)
 	}
 
-	// Генерируем JWT токен
-	token, tokenErr := s.generateToken(user.ID, user.Login)
+	s.audit.Record(userAuditEvent(user.ID, domain.AuditUserPasswordChanged, meta))
+
+	token, tokenErr := s.issueTokens(user)
 	if tokenErr != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", tokenErr)
 	}
 
 	return token, nil
 }
+
+// Refresh обменивает refresh-токен на новую пару токенов.
+// Каждый refresh-токен действует один раз; повторное предъявление отзывает сессию.
+func (s *UserService) Refresh(
+	ctx context.Context,
+	refreshToken string,
+	meta domain.RequestMeta,
+) (*domain.AuthToken, error) {
+	newRefreshToken, newRefreshHash, genErr := generateRefreshToken()
+	if genErr != nil {
+		return nil, genErr
+	}
+
+	session, rotateErr := s.sessions.Rotate(
+		hashRefreshToken(refreshToken),
+		newRefreshHash,
+		time.Now().Add(s.refreshTTL),
+	)
+	if rotateErr != nil {
+		switch {
+		case errors.Is(rotateErr, domain.ErrRefreshTokenReused):
+			s.audit.Record(domain.AuditEvent{
+				ActorType: domain.AuditActorAnonymous,
+				Action:    domain.AuditRefreshTokenReused,
+				IP:        meta.IP,
+				UserAgent: meta.UserAgent,
+			})
+			return nil, ErrInvalidRefreshToken
+		case errors.Is(rotateErr, domain.ErrRefreshTokenInvalid),
+			errors.Is(rotateErr, domain.ErrSessionRevoked):
+			return nil, ErrInvalidRefreshToken
+		default:
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", rotateErr)
+		}
+	}
+
+	user, findErr := s.repo.FindByID(ctx, session.UserID)
+	if findErr != nil {
+		return nil, fmt.Errorf("failed to find user: %w", findErr)
+	}
+
+	accessToken, signErr := s.generateToken(user, session.ID)
+	if signErr != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", signErr)
+	}
+
+	return &domain.AuthToken{
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
+}
+
+// Logout отзывает текущую сессию пользователя.
+func (s *UserService) Logout(ctx context.Context, userID int, sessionID int64, meta domain.RequestMeta) error {
+	if err := s.sessions.Revoke(sessionID, userID, domain.SessionRevokeLogout); err != nil {
+		return err
+	}
+
+	event := userAuditEvent(userID, domain.AuditUserLogout, meta)
+	event.Payload = auditPayload(map[string]interface{}{"session_id": sessionID})
+	s.audit.Record(event)
+	return nil
+}
+
+// LogoutAll отзывает все сессии пользователя.
+func (s *UserService) LogoutAll(ctx context.Context, userID int, meta domain.RequestMeta) error {
+	if err := s.sessions.RevokeAll(userID, domain.SessionRevokeLogoutAll); err != nil {
+		return err
+	}
+
+	s.audit.Record(userAuditEvent(userID, domain.AuditUserLogoutAll, meta))
+	return nil
+}
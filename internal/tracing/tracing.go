@@ -0,0 +1,105 @@
+// Package tracing настраивает трассировку OpenTelemetry и переносит контекст трассировки
+// между запросом, загрузившим заказ, и воркером, который его обрабатывает.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры трассировки.
+const (
+	ExporterNone   = "none"   // span не экспортируются, контекст трассировки только передается дальше
+	ExporterOTLP   = "otlp"   // экспорт по OTLP/HTTP
+	ExporterStdout = "stdout" // вывод span в stdout для локальной отладки
+)
+
+// traceParentHeader заголовок W3C Trace Context с идентификаторами трассировки и span.
+const traceParentHeader = "traceparent"
+
+// Config содержит настройки трассировки.
+type Config struct {
+	ServiceName  string // Имя сервиса в ресурсе трассировки
+	Exporter     string // Экспортер: none, otlp или stdout
+	OTLPEndpoint string // URL коллектора OTLP/HTTP (пусто - из переменных OTEL_EXPORTER_OTLP_*)
+}
+
+// Setup настраивает глобальные TracerProvider и propagator W3C Trace Context.
+// Возвращает функцию, которая отправляет накопленные span и останавливает экспорт.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// TraceParent возвращает заголовок traceparent текущего span из ctx или пустую строку,
+// если запрос не трассируется.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader)
+}
+
+// LinkFromTraceParent возвращает ссылку на span, сохраненный заголовком traceparent.
+// Второе значение false, если заголовок пуст или некорректен.
+func LinkFromTraceParent(traceParent string) (trace.Link, bool) {
+	if traceParent == "" {
+		return trace.Link{}, false
+	}
+
+	carrier := propagation.MapCarrier{traceParentHeader: traceParent}
+	spanContext := trace.SpanContextFromContext(
+		propagation.TraceContext{}.Extract(context.Background(), carrier),
+	)
+	if !spanContext.IsValid() {
+		return trace.Link{}, false
+	}
+
+	return trace.Link{SpanContext: spanContext}, true
+}
@@ -2,14 +2,21 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
-	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"gophermart/internal/domain"
-	"gophermart/internal/service"
+	"gophermart/internal/tracing"
 )
 
 // contextKey используется для ключей контекста.
@@ -19,62 +26,111 @@ const (
 	defaultWorkerCount  = 5
 	defaultPollInterval = 1 * time.Second
 	defaultRetryTimeout = 1 * time.Minute
+	// defaultBatchSize количество заказов, захватываемых воркером за один проход.
+	defaultBatchSize = 10
+	// defaultLeaseDuration время аренды заказа; должно с запасом покрывать обработку всей пачки.
+	defaultLeaseDuration = 2 * time.Minute
+	// defaultRetryBaseDelay задержка перед повторным опросом после первой попытки.
+	defaultRetryBaseDelay = 1 * time.Second
+	// defaultRetryMaxDelay максимальная задержка между опросами одного заказа.
+	defaultRetryMaxDelay = 10 * time.Minute
 
 	workerIDKey = contextKey("worker_id")
+
+	// tracerName имя трассировщика воркера начислений.
+	tracerName = "gophermart/internal/worker"
 )
 
+// Config содержит настройки воркера начислений. Нулевые значения заменяются значениями по умолчанию.
+type Config struct {
+	WorkerCount    int             // Количество воркеров
+	PollInterval   time.Duration   // Интервал опроса очереди заказов
+	RetryTimeout   time.Duration   // Интервал опроса после ошибки работы с очередью
+	BatchSize      int             // Количество заказов, захватываемых за один проход
+	LeaseDuration  time.Duration   // Время аренды захваченного заказа
+	RetryBaseDelay time.Duration   // Начальная задержка повторного опроса заказа
+	RetryMaxDelay  time.Duration   // Максимальная задержка повторного опроса заказа
+	MaxAttempts    int             // Число попыток, после которого заказ переводится в INVALID (0 - без ограничения)
+	MaxAge         time.Duration   // Возраст заказа, после которого он переводится в INVALID (0 - без ограничения)
+	Wakeup         <-chan struct{} // Сигналы о новых заказах (nil - только периодический опрос)
+}
+
+// withDefaults возвращает копию конфигурации с заполненными значениями по умолчанию.
+func (c Config) withDefaults() Config {
+	if c.WorkerCount <= 0 {
+		c.WorkerCount = defaultWorkerCount
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.RetryTimeout <= 0 {
+		c.RetryTimeout = defaultRetryTimeout
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.LeaseDuration <= 0 {
+		c.LeaseDuration = defaultLeaseDuration
+	}
+	if c.RetryBaseDelay <= 0 {
+		c.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	if c.RetryMaxDelay <= 0 {
+		c.RetryMaxDelay = defaultRetryMaxDelay
+	}
+	return c
+}
+
+// Metrics метрики, которые учитывает воркер: окончательные статусы заказов и начисленные баллы.
+type Metrics interface {
+	domain.WorkerMetrics
+	ObserveAccrualCredited(amount domain.Money)
+}
+
 // AccrualWorker обработчик заказов для получения информации о начислениях.
 type AccrualWorker struct {
-	logger         *slog.Logger
-	orderRepo      domain.OrderRepository
-	accrualService *service.AccrualService
-	workerCount    int
-	pollInterval   time.Duration
-	retryTimeout   time.Duration
+	logger        *slog.Logger
+	orderRepo     domain.OrderRepository
+	accrualClient domain.AccrualClient
+	audit         domain.AuditRecorder
+	metrics       Metrics
+	config        Config
 }
 
 // NewAccrualWorker создает новый экземпляр AccrualWorker.
 func NewAccrualWorker(
 	logger *slog.Logger,
 	orderRepo domain.OrderRepository,
-	accrualService *service.AccrualService,
-	workerCount int,
-	pollInterval time.Duration,
-	retryTimeout time.Duration,
+	accrualClient domain.AccrualClient,
+	audit domain.AuditRecorder,
+	metrics Metrics,
+	cfg Config,
 ) *AccrualWorker {
-	if workerCount <= 0 {
-		workerCount = defaultWorkerCount
-	}
-	if pollInterval <= 0 {
-		pollInterval = defaultPollInterval
-	}
-	if retryTimeout <= 0 {
-		retryTimeout = defaultRetryTimeout
-	}
-
 	return &AccrualWorker{
 		logger: logger.With(
 			"package", "worker",
 			"component", "AccrualWorker",
 		),
-		orderRepo:      orderRepo,
-		accrualService: accrualService,
-		workerCount:    workerCount,
-		pollInterval:   pollInterval,
-		retryTimeout:   retryTimeout,
+		orderRepo:     orderRepo,
+		accrualClient: accrualClient,
+		audit:         audit,
+		metrics:       metrics,
+		config:        cfg.withDefaults(),
 	}
 }
 
-// Start запускает обработку заказов.
-func (w *AccrualWorker) Start(ctx context.Context) {
+// Start запускает обработку заказов и возвращает управление, когда все воркеры завершились.
+// Отмена claimCtx прекращает захват новых заказов: воркеры доводят до конца текущий заказ,
+// а остальные захваченные возвращают в очередь. Отмена ctx прерывает и текущие запросы.
+func (w *AccrualWorker) Start(claimCtx, ctx context.Context) {
 	var wg sync.WaitGroup
 
 	// Запускаем пул воркеров
-	for workerID := range w.workerCount {
+	for workerID := range w.config.WorkerCount {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			w.worker(ctx, workerID)
+			w.worker(claimCtx, ctx, workerID)
 		}(workerID)
 	}
 
@@ -83,7 +139,7 @@ func (w *AccrualWorker) Start(ctx context.Context) {
 }
 
 // worker обрабатывает заказы.
-func (w *AccrualWorker) worker(ctx context.Context, id int) {
+func (w *AccrualWorker) worker(claimCtx, ctx context.Context, id int) {
 	// Создаем отдельный логгер для этого воркера
 	workerLogger := w.logger.With("worker_id", id)
 	workerLogger.Info("воркер начал работу")
@@ -91,113 +147,284 @@ func (w *AccrualWorker) worker(ctx context.Context, id int) {
 	// Добавляем worker_id в контекст
 	ctx = context.WithValue(ctx, workerIDKey, id)
 
-	ticker := time.NewTicker(w.pollInterval)
+	// Периодический опрос страхует от потерянных уведомлений и подхватывает отложенные повторы
+	ticker := time.NewTicker(w.config.PollInterval)
 	defer ticker.Stop()
 
+	failing := false
 	for {
 		select {
-		case <-ctx.Done():
+		case <-claimCtx.Done():
 			workerLogger.Info("воркер завершил работу")
 			return
-		case <-ticker.C:
-			if err := w.processOrders(ctx, workerLogger); err != nil {
-				workerLogger.Error("ошибка обработки заказов", "error", err)
-				// Увеличиваем интервал опроса при ошибках
-				ticker.Reset(w.retryTimeout)
-			} else {
-				// Возвращаем нормальный интервал опроса
-				ticker.Reset(w.pollInterval)
+		case <-w.config.Wakeup:
+			// После ошибки выдерживаем увеличенный интервал, даже если пришли новые заказы
+			if failing {
+				continue
 			}
+			workerLogger.Debug("получен сигнал о новом заказе")
+		case <-ticker.C:
+		}
+
+		if err := w.processOrders(claimCtx, ctx, workerLogger); err != nil {
+			workerLogger.Error("ошибка обработки заказов", "error", err)
+			// Увеличиваем интервал опроса при ошибках
+			failing = true
+			ticker.Reset(w.config.RetryTimeout)
+		} else {
+			// Возвращаем нормальный интервал опроса
+			failing = false
+			ticker.Reset(w.config.PollInterval)
 		}
 	}
 }
 
-// processOrders обрабатывает заказы, ожидающие обновления статуса.
-func (w *AccrualWorker) processOrders(ctx context.Context, logger *slog.Logger) error {
+// processOrders обрабатывает пачку заказов, время опроса которых наступило.
+// Захват и проверка перед каждым заказом выполняются по claimCtx, обработка заказа - в ctx.
+func (w *AccrualWorker) processOrders(claimCtx, ctx context.Context, logger *slog.Logger) (err error) {
+	// Каждый проход - отдельная трассировка; связь с запросом пользователя задают ссылки в span заказов
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "AccrualWorker.processOrders", trace.WithNewRoot())
+	defer func() { endSpan(span, err) }()
+
 	logger = logger.With("method", "processOrders")
 	logger.Debug("начало обработки заказов")
 
-	// Получаем заказы для обработки (NEW или PROCESSING)
+	// Захватываем пачку заказов для обработки (NEW или PROCESSING), не пересекающуюся с другими воркерами
 	statuses := []domain.OrderStatus{domain.OrderStatusNew, domain.OrderStatusProcessing}
-	logger.Debug("запрос заказов", "статусы", statuses)
+	logger.Debug("захват заказов", "статусы", statuses)
 
-	orders, findErr := w.orderRepo.FindByStatus(statuses)
-	if findErr != nil {
-		logger.Error("ошибка при поиске заказов",
-			"error", findErr,
-			"error_type", fmt.Sprintf("%T", findErr),
+	orders, claimErr := w.orderRepo.ClaimDue(ctx, statuses, w.config.BatchSize, w.config.LeaseDuration)
+	if claimErr != nil {
+		logger.Error("ошибка при захвате заказов",
+			"error", claimErr,
+			"error_type", fmt.Sprintf("%T", claimErr),
 		)
-		return findErr
+		return claimErr
 	}
 
 	logger.Debug("кол-во заказов для обработки воркером", "количество", len(orders))
 
-	for _, order := range orders {
-		// Проверяем контекст перед каждым заказом
-		if ctx.Err() != nil {
-			logger.Debug("контекст отменен", "error", ctx.Err())
-			return ctx.Err()
+	for i, order := range orders {
+		// Воркер останавливается: не начинаем новые заказы и возвращаем их в очередь,
+		// не дожидаясь истечения аренды
+		if claimCtx.Err() != nil || ctx.Err() != nil {
+			logger.Info("остановка воркера, необработанные заказы возвращены в очередь", "количество", len(orders)-i)
+			w.releaseClaims(ctx, logger, orders[i:])
+			return nil
 		}
 
-		logger.Debug("обработка заказа",
-			"id заказа", order.ID,
-			"номер заказа", order.Number,
-			"текущий статус", order.Status)
-
-		// Получаем информацию о начислении
-		accrual, accrualErr := w.accrualService.GetOrderAccrual(ctx, order.Number)
-		if accrualErr != nil {
-			var rateLimitErr *service.RateLimitError
-			if errors.As(accrualErr, &rateLimitErr) {
-				w.logger.Info("rate limit exceeded, waiting",
-					"order_number", order.Number,
-					"retry_after", rateLimitErr.RetryAfter)
-				time.Sleep(rateLimitErr.RetryAfter)
-				continue
-			}
-			w.logger.Error("failed to get order accrual",
-				"order_number", order.Number,
-				"error", accrualErr)
-			continue
+		if err = w.processOrder(ctx, logger, order); err != nil {
+			// Система начислений недоступна: не перебираем остаток пачки, а откладываем опрос целиком
+			logger.Warn("обработка пачки прервана", "error", err)
+			w.releaseClaims(ctx, logger, orders[i:])
+			return err
 		}
+	}
+
+	return nil
+}
+
+// processOrder запрашивает начисление по заказу и обновляет его статус.
+// Каждый путь завершается снятием аренды: окончательным статусом, новой попыткой или освобождением заказа.
+// Возвращает ошибку, только если обработку всей пачки нужно прервать; аренду заказа в этом случае
+// снимает вызывающий.
+func (w *AccrualWorker) processOrder(ctx context.Context, logger *slog.Logger, order domain.Order) (err error) {
+	ctx, span := w.startOrderSpan(ctx, order)
+	defer func() { endSpan(span, err) }()
 
-		// Если заказ не найден, пропускаем
-		if accrual == nil {
-			logger.Debug("заказ не найден в системе начислений",
-				"номер заказа", order.Number)
-			continue
+	logger = logger.With(
+		"id заказа", order.ID,
+		"номер заказа", order.Number,
+		"текущий статус", order.Status,
+		"попытка", order.Attempts+1,
+	)
+	logger.Debug("обработка заказа")
+
+	// Заказы, которые слишком долго не удается рассчитать, перестают расходовать квоту системы начислений
+	if reason := w.expiredReason(order); reason != "" {
+		if markErr := w.orderRepo.MarkFailed(ctx, order.ID, reason); markErr != nil {
+			if errors.Is(markErr, domain.ErrInvalidOrderTransition) {
+				logger.Warn("заказ уже в окончательном статусе", "error", markErr)
+			} else {
+				logger.Error("ошибка перевода заказа в INVALID", "error", markErr)
+			}
+			w.releaseClaims(ctx, logger, []domain.Order{order})
+			return nil
 		}
+		w.metrics.ObserveOrderFinalized(domain.OrderStatusInvalid, time.Since(order.UploadedAt))
+		w.recordAudit(domain.AuditAccrualInvalid, order, map[string]interface{}{"reason": reason})
+		return nil
+	}
 
-		logger.Debug("получена информация о начислении",
-			"номер заказа", order.Number,
-			"статус", accrual.Status,
-			"начисление", accrual.Accrual)
+	// Получаем информацию о начислении
+	accrual, accrualErr := w.accrualClient.GetOrderAccrual(ctx, order.Number)
+	if accrualErr != nil {
+		if errors.Is(accrualErr, domain.ErrAccrualCircuitOpen) {
+			return accrualErr
+		}
 
-		// Обновляем статус заказа
-		if updateStatusErr := w.orderRepo.UpdateStatus(order.ID, accrual.Status); updateStatusErr != nil {
-			logger.Error("ошибка обновления статуса заказа",
-				"номер заказа", order.Number,
-				"статус", accrual.Status,
-				"error", updateStatusErr)
-			continue
+		// Превышение лимита и остановка не считаются попыткой: возвращаем заказ в очередь.
+		// Паузу после 429 выдерживает общий ограничитель клиента для всех воркеров сразу.
+		var rateLimitErr *domain.AccrualRateLimitError
+		if errors.As(accrualErr, &rateLimitErr) || ctx.Err() != nil {
+			logger.Info("запрос не выполнен, заказ возвращен в очередь", "error", accrualErr)
+			w.releaseClaims(ctx, logger, []domain.Order{order})
+			return nil
+		}
+		if errors.Is(accrualErr, domain.ErrAccrualOrderNotFound) {
+			logger.Debug("заказ не найден в системе начислений")
+		} else {
+			logger.Error("failed to get order accrual", "error", accrualErr)
 		}
+		w.scheduleRetry(ctx, logger, order, accrualErr.Error())
+		return nil
+	}
 
-		// Если есть начисление, обновляем сумму
-		if accrual.Status == domain.OrderStatusProcessed && accrual.Accrual != nil {
-			accrualKop := int64(*accrual.Accrual * domain.KopPerRuble) // конвертируем рубли в копейки
-			logger.Debug("обновление суммы начисления",
-				"номер заказа", order.Number,
-				"начисление (руб)", *accrual.Accrual,
-				"начисление (коп)", accrualKop)
-
-			if updateAccrualErr := w.orderRepo.UpdateAccrual(order.ID, accrualKop); updateAccrualErr != nil {
-				logger.Error("ошибка обновления суммы начисления",
-					"номер заказа", order.Number,
-					"начисление (коп)", accrualKop,
-					"error", updateAccrualErr)
+	logger.Debug("получена информация о начислении",
+		"статус", accrual.Status,
+		"начисление", accrual.Accrual)
+
+	// Ответ уже получен: сохраняем его, даже если воркер начал останавливаться
+	ctx = context.WithoutCancel(ctx)
+
+	switch accrual.Status {
+	case domain.OrderStatusProcessed, domain.OrderStatusInvalid:
+		w.applyFinalStatus(ctx, logger, order, accrual)
+	case domain.OrderStatusProcessing:
+		if order.Status != domain.OrderStatusProcessing {
+			updateErr := w.orderRepo.ApplyAccrual(ctx, order.ID, domain.OrderStatusProcessing, nil)
+			if errors.Is(updateErr, domain.ErrInvalidOrderTransition) {
+				// Заказ уже обработан другим ответом: опрашивать его больше не нужно
+				logger.Warn("устаревший ответ системы начислений", "error", updateErr)
+				w.releaseClaims(ctx, logger, []domain.Order{order})
+				return nil
+			}
+			if updateErr != nil {
+				logger.Error("ошибка обновления статуса заказа", "статус", accrual.Status, "error", updateErr)
 			}
 		}
+		w.scheduleRetry(ctx, logger, order, "расчет начисления в процессе")
+	case domain.OrderStatusNew, domain.OrderStatusRegistered:
+		w.scheduleRetry(ctx, logger, order, "расчет начисления не начат")
+	default:
+		w.scheduleRetry(ctx, logger, order, fmt.Sprintf("неизвестный статус %q", accrual.Status))
 	}
 
 	return nil
 }
+
+// startOrderSpan начинает span обработки заказа со ссылкой на трассировку запроса, загрузившего заказ.
+func (w *AccrualWorker) startOrderSpan(ctx context.Context, order domain.Order) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.Int("order.id", order.ID),
+			attribute.String("order.number", order.Number),
+			attribute.String("order.status", string(order.Status)),
+			attribute.Int("order.attempt", order.Attempts+1),
+		),
+	}
+	if order.TraceParent != nil {
+		if link, ok := tracing.LinkFromTraceParent(*order.TraceParent); ok {
+			opts = append(opts, trace.WithLinks(link))
+		}
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, "AccrualWorker.processOrder", opts...)
+}
+
+// endSpan завершает span, отмечая в нем ошибку.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// applyFinalStatus сохраняет окончательный статус заказа и начисление.
+func (w *AccrualWorker) applyFinalStatus(
+	ctx context.Context,
+	logger *slog.Logger,
+	order domain.Order,
+	accrual *domain.OrderAccrual,
+) {
+	// Статус, начисление и зачисление баллов сохраняются одной транзакцией
+	var amount *domain.Money
+	if accrual.Status == domain.OrderStatusProcessed {
+		amount = accrual.Accrual
+	}
+	if applyErr := w.orderRepo.ApplyAccrual(ctx, order.ID, accrual.Status, amount); applyErr != nil {
+		if errors.Is(applyErr, domain.ErrInvalidOrderTransition) {
+			logger.Warn("ответ системы начислений отклонен", "статус", accrual.Status, "error", applyErr)
+			w.releaseClaims(ctx, logger, []domain.Order{order})
+			return
+		}
+		logger.Error("ошибка сохранения ответа системы начислений",
+			"статус", accrual.Status,
+			"начисление", amount,
+			"error", applyErr)
+		w.scheduleRetry(ctx, logger, order, applyErr.Error())
+		return
+	}
+	if amount != nil {
+		w.metrics.ObserveAccrualCredited(*amount)
+	}
+
+	w.releaseClaims(ctx, logger, []domain.Order{order})
+	w.metrics.ObserveOrderFinalized(accrual.Status, time.Since(order.UploadedAt))
+
+	if accrual.Status == domain.OrderStatusProcessed {
+		w.recordAudit(domain.AuditAccrualProcessed, order, map[string]interface{}{"accrual": accrual.Accrual})
+	} else {
+		w.recordAudit(domain.AuditAccrualInvalid, order, map[string]interface{}{"reason": "отклонен системой начислений"})
+	}
+}
+
+// recordAudit записывает окончательное решение по заказу в журнал аудита от имени воркера.
+func (w *AccrualWorker) recordAudit(action domain.AuditAction, order domain.Order, fields map[string]interface{}) {
+	fields["number"] = order.Number
+	fields["user_id"] = order.UserID
+	payload, _ := json.Marshal(fields)
+
+	w.audit.Record(domain.AuditEvent{
+		ActorType:  domain.AuditActorSystem,
+		Action:     action,
+		TargetType: domain.AuditTargetOrder,
+		TargetID:   strconv.Itoa(order.ID),
+		Payload:    payload,
+	})
+}
+
+// expiredReason возвращает причину прекращения опроса заказа или пустую строку.
+func (w *AccrualWorker) expiredReason(order domain.Order) string {
+	if w.config.MaxAttempts > 0 && order.Attempts >= w.config.MaxAttempts {
+		return fmt.Sprintf("превышено число попыток получить расчет (%d)", order.Attempts)
+	}
+	if w.config.MaxAge > 0 && time.Since(order.UploadedAt) > w.config.MaxAge {
+		return fmt.Sprintf("расчет не получен за %s", w.config.MaxAge)
+	}
+	return ""
+}
+
+// scheduleRetry назначает следующий опрос заказа с экспоненциальной задержкой.
+func (w *AccrualWorker) scheduleRetry(ctx context.Context, logger *slog.Logger, order domain.Order, reason string) {
+	delay := backoffDelay(order.Attempts, w.config.RetryBaseDelay, w.config.RetryMaxDelay)
+	logger.Debug("повторный опрос заказа отложен", "задержка", delay, "причина", reason)
+
+	if err := w.orderRepo.ScheduleRetry(ctx, order.ID, time.Now().Add(delay), reason); err != nil {
+		logger.Error("ошибка планирования повторного опроса", "error", err)
+		w.releaseClaims(ctx, logger, []domain.Order{order})
+	}
+}
+
+// releaseClaims снимает аренду с заказов. Аренда снимается и после отмены ctx при остановке воркера.
+func (w *AccrualWorker) releaseClaims(ctx context.Context, logger *slog.Logger, orders []domain.Order) {
+	ctx = context.WithoutCancel(ctx)
+	for _, order := range orders {
+		if err := w.orderRepo.ReleaseClaim(ctx, order.ID); err != nil {
+			logger.Error("ошибка снятия аренды с заказа",
+				"номер заказа", order.Number,
+				"error", err)
+		}
+	}
+}
@@ -0,0 +1,636 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gophermart/internal/accrualfake"
+	"gophermart/internal/domain"
+)
+
+const testRetryBaseDelay = time.Second
+
+// memOrderRepo реализация domain.OrderRepository в памяти для тестов воркера.
+// Переходы статусов проверяются так же, как в OrderRepo.
+type memOrderRepo struct {
+	mu       sync.Mutex
+	orders   map[int]*domain.Order
+	history  map[int][]domain.OrderStatusChange
+	released []int
+	retries  []memRetry
+}
+
+// memRetry зафиксированный вызов ScheduleRetry.
+type memRetry struct {
+	OrderID       int
+	NextAttemptAt time.Time
+	LastError     string
+	ScheduledAt   time.Time
+}
+
+func newMemOrderRepo(orders ...domain.Order) *memOrderRepo {
+	r := &memOrderRepo{
+		orders:  make(map[int]*domain.Order),
+		history: make(map[int][]domain.OrderStatusChange),
+	}
+	for i := range orders {
+		order := orders[i]
+		if order.ID == 0 {
+			order.ID = i + 1
+		}
+		r.orders[order.ID] = &order
+	}
+	return r
+}
+
+// order возвращает копию заказа по номеру.
+func (r *memOrderRepo) order(t *testing.T, number string) domain.Order {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range r.orders {
+		if order.Number == number {
+			return *order
+		}
+	}
+	t.Fatalf("order %s not found", number)
+	return domain.Order{}
+}
+
+// makeDue делает все заказы доступными для следующего прохода, как будто задержка повтора истекла.
+func (r *memOrderRepo) makeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range r.orders {
+		order.NextAttemptAt = time.Time{}
+		order.LockedUntil = nil
+	}
+}
+
+func (r *memOrderRepo) Create(_ context.Context, order *domain.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order.ID = len(r.orders) + 1
+	order.UploadedAt = time.Now()
+	stored := *order
+	r.orders[order.ID] = &stored
+	return nil
+}
+
+func (r *memOrderRepo) FindByNumber(_ context.Context, number string) (*domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range r.orders {
+		if order.Number == number {
+			found := *order
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memOrderRepo) FindByUserID(_ context.Context, userID int, _ domain.OrderFilter) ([]domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orders []domain.Order
+	for _, order := range r.orders {
+		if order.UserID == userID {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func (r *memOrderRepo) FindStatusHistory(_ context.Context, orderID int) ([]domain.OrderStatusChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]domain.OrderStatusChange{}, r.history[orderID]...), nil
+}
+
+func (r *memOrderRepo) ClaimDue(
+	_ context.Context,
+	statuses []domain.OrderStatus,
+	limit int,
+	lease time.Duration,
+) ([]domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	ids := make([]int, 0, len(r.orders))
+	for id := range r.orders {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var claimed []domain.Order
+	for _, id := range ids {
+		order := r.orders[id]
+		if len(claimed) == limit {
+			break
+		}
+		if !containsStatus(statuses, order.Status) || order.NextAttemptAt.After(now) ||
+			(order.LockedUntil != nil && order.LockedUntil.After(now)) {
+			continue
+		}
+		lockedUntil := now.Add(lease)
+		order.LockedUntil = &lockedUntil
+		claimed = append(claimed, *order)
+	}
+	return claimed, nil
+}
+
+func (r *memOrderRepo) ReleaseClaim(_ context.Context, orderID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.orders[orderID].LockedUntil = nil
+	r.released = append(r.released, orderID)
+	return nil
+}
+
+func (r *memOrderRepo) ScheduleRetry(_ context.Context, orderID int, nextAttemptAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.orders[orderID]
+	order.Attempts++
+	order.LastError = &lastError
+	order.NextAttemptAt = nextAttemptAt
+	order.LockedUntil = nil
+	r.retries = append(r.retries, memRetry{
+		OrderID:       orderID,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     lastError,
+		ScheduledAt:   time.Now(),
+	})
+	return nil
+}
+
+func (r *memOrderRepo) MarkFailed(_ context.Context, orderID int, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.orders[orderID]
+	if err := domain.ValidateOrderTransition(order.Status, domain.OrderStatusInvalid); err != nil {
+		return err
+	}
+	r.history[orderID] = append(r.history[orderID], domain.OrderStatusChange{
+		From:   order.Status,
+		To:     domain.OrderStatusInvalid,
+		Reason: &reason,
+	})
+	order.Status = domain.OrderStatusInvalid
+	order.FailureReason = &reason
+	order.LockedUntil = nil
+	return nil
+}
+
+func (r *memOrderRepo) ApplyAccrual(
+	_ context.Context,
+	orderID int,
+	status domain.OrderStatus,
+	accrual *domain.Money,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.orders[orderID]
+	if order.Status == status && !status.IsFinal() {
+		return nil
+	}
+	if err := domain.ValidateOrderTransition(order.Status, status); err != nil {
+		return err
+	}
+
+	attempt := order.Attempts + 1
+	r.history[orderID] = append(r.history[orderID], domain.OrderStatusChange{
+		From:    order.Status,
+		To:      status,
+		Accrual: accrual,
+		Attempt: &attempt,
+	})
+	order.Status = status
+	order.Accrual = accrual
+	if status.IsFinal() {
+		order.Attempts++
+	}
+	return nil
+}
+
+func (r *memOrderRepo) QueueStats(context.Context) (*domain.OrderQueueStats, error) {
+	return &domain.OrderQueueStats{}, nil
+}
+
+func containsStatus(statuses []domain.OrderStatus, status domain.OrderStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// stubMetrics учитывает окончательные статусы и начисления в памяти.
+type stubMetrics struct {
+	finalized []domain.OrderStatus
+	credited  []domain.Money
+}
+
+func (m *stubMetrics) ObserveOrderFinalized(status domain.OrderStatus, _ time.Duration) {
+	m.finalized = append(m.finalized, status)
+}
+
+func (m *stubMetrics) ObserveAccrualCredited(amount domain.Money) {
+	m.credited = append(m.credited, amount)
+}
+
+// stubAudit сохраняет записанные события аудита.
+type stubAudit struct {
+	events []domain.AuditEvent
+}
+
+func (a *stubAudit) Record(event domain.AuditEvent) {
+	a.events = append(a.events, event)
+}
+
+// testEnv воркер с фиктивной системой начислений и хранилищем в памяти.
+type testEnv struct {
+	worker  *AccrualWorker
+	repo    *memOrderRepo
+	client  *accrualfake.Client
+	metrics *stubMetrics
+	audit   *stubAudit
+}
+
+func newTestEnv(cfg Config, orders ...domain.Order) *testEnv {
+	env := &testEnv{
+		repo:    newMemOrderRepo(orders...),
+		client:  accrualfake.New(),
+		metrics: &stubMetrics{},
+		audit:   &stubAudit{},
+	}
+	cfg.RetryBaseDelay = testRetryBaseDelay
+	env.worker = NewAccrualWorker(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		env.repo,
+		env.client,
+		env.audit,
+		env.metrics,
+		cfg,
+	)
+	return env
+}
+
+// run выполняет проходы воркера, перед каждым следующим делая заказы доступными.
+// Возвращает ошибку последнего прохода.
+func (e *testEnv) run(passes int) error {
+	var err error
+	for i := range passes {
+		if i > 0 {
+			e.repo.makeDue()
+		}
+		err = e.worker.processOrders(context.Background(), context.Background(), e.worker.logger)
+	}
+	return err
+}
+
+func newOrder(number string) domain.Order {
+	return domain.Order{
+		Number:     number,
+		UserID:     1,
+		Status:     domain.OrderStatusNew,
+		UploadedAt: time.Now(),
+	}
+}
+
+func statusPath(history []domain.OrderStatusChange) []domain.OrderStatus {
+	path := make([]domain.OrderStatus, 0, len(history))
+	for _, change := range history {
+		path = append(path, change.To)
+	}
+	return path
+}
+
+func TestProcessOrders(t *testing.T) {
+	accrual := domain.NewMoney(729, 98)
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		orders []domain.Order
+		steps  map[string][]accrualfake.Step
+		passes int
+		check  func(t *testing.T, e *testEnv, err error)
+	}{
+		{
+			name:   "registered then processing then processed",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {
+					accrualfake.Status(domain.OrderStatusRegistered),
+					accrualfake.Status(domain.OrderStatusProcessing),
+					accrualfake.Processed(accrual),
+				},
+			},
+			passes: 3,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusProcessed {
+					t.Errorf("status = %s, want PROCESSED", order.Status)
+				}
+				if order.Accrual == nil || *order.Accrual != accrual {
+					t.Errorf("accrual = %v, want %v", order.Accrual, accrual)
+				}
+				history, _ := e.repo.FindStatusHistory(context.Background(), order.ID)
+				wantPath := []domain.OrderStatus{domain.OrderStatusProcessing, domain.OrderStatusProcessed}
+				if got := statusPath(history); !equalStatuses(got, wantPath) {
+					t.Errorf("history = %v, want %v", got, wantPath)
+				}
+				if order.Attempts != 3 {
+					t.Errorf("attempts = %d, want 3", order.Attempts)
+				}
+				if len(e.metrics.credited) != 1 || e.metrics.credited[0] != accrual {
+					t.Errorf("credited = %v, want [%v]", e.metrics.credited, accrual)
+				}
+				if len(e.audit.events) != 1 || e.audit.events[0].Action != domain.AuditAccrualProcessed {
+					t.Errorf("audit events = %+v, want one %s", e.audit.events, domain.AuditAccrualProcessed)
+				}
+			},
+		},
+		{
+			name:   "registered then processing then invalid",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {
+					accrualfake.Status(domain.OrderStatusRegistered),
+					accrualfake.Status(domain.OrderStatusProcessing),
+					accrualfake.Status(domain.OrderStatusInvalid),
+				},
+			},
+			passes: 3,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusInvalid {
+					t.Errorf("status = %s, want INVALID", order.Status)
+				}
+				if order.Accrual != nil {
+					t.Errorf("accrual = %v, want nil", *order.Accrual)
+				}
+				history, _ := e.repo.FindStatusHistory(context.Background(), order.ID)
+				wantPath := []domain.OrderStatus{domain.OrderStatusProcessing, domain.OrderStatusInvalid}
+				if got := statusPath(history); !equalStatuses(got, wantPath) {
+					t.Errorf("history = %v, want %v", got, wantPath)
+				}
+				if len(e.metrics.credited) != 0 {
+					t.Errorf("credited = %v, want none", e.metrics.credited)
+				}
+			},
+		},
+		{
+			name:   "registered keeps order new and schedules retry",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.Status(domain.OrderStatusRegistered)},
+			},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusNew {
+					t.Errorf("status = %s, want NEW", order.Status)
+				}
+				if len(e.repo.retries) != 1 {
+					t.Errorf("retries = %d, want 1", len(e.repo.retries))
+				}
+			},
+		},
+		{
+			name:   "not found schedules retry with backoff",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.NotFound()},
+			},
+			passes: 2,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusNew {
+					t.Errorf("status = %s, want NEW", order.Status)
+				}
+				if order.Attempts != 2 {
+					t.Errorf("attempts = %d, want 2", order.Attempts)
+				}
+				if len(e.repo.retries) != 2 {
+					t.Fatalf("retries = %d, want 2", len(e.repo.retries))
+				}
+				// Задержка растет вдвое с каждой попыткой, джиттер оставляет от половины до полной задержки
+				for i, retry := range e.repo.retries {
+					maxDelay := testRetryBaseDelay << i
+					delay := retry.NextAttemptAt.Sub(retry.ScheduledAt)
+					if delay < maxDelay/2-time.Millisecond || delay > maxDelay {
+						t.Errorf("retry %d delay = %v, want between %v and %v", i, delay, maxDelay/2, maxDelay)
+					}
+					if retry.LastError != domain.ErrAccrualOrderNotFound.Error() {
+						t.Errorf("retry %d last error = %q", i, retry.LastError)
+					}
+				}
+			},
+		},
+		{
+			name:   "rate limit releases claim without counting attempt",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.RateLimited(time.Minute)},
+			},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Attempts != 0 {
+					t.Errorf("attempts = %d, want 0", order.Attempts)
+				}
+				if len(e.repo.retries) != 0 {
+					t.Errorf("retries = %d, want 0", len(e.repo.retries))
+				}
+				if len(e.repo.released) != 1 || e.repo.released[0] != order.ID {
+					t.Errorf("released = %v, want [%d]", e.repo.released, order.ID)
+				}
+				if order.LockedUntil != nil {
+					t.Errorf("order is still claimed until %v", *order.LockedUntil)
+				}
+			},
+		},
+		{
+			name:   "malformed response schedules retry",
+			orders: []domain.Order{newOrder("12345678903")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.Malformed()},
+			},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusNew {
+					t.Errorf("status = %s, want NEW", order.Status)
+				}
+				if order.Attempts != 1 {
+					t.Errorf("attempts = %d, want 1", order.Attempts)
+				}
+				if order.LastError == nil {
+					t.Fatal("last error is not recorded")
+				}
+				if len(e.repo.retries) != 1 {
+					t.Errorf("retries = %d, want 1", len(e.repo.retries))
+				}
+			},
+		},
+		{
+			name:   "open breaker aborts batch",
+			orders: []domain.Order{newOrder("12345678903"), newOrder("2377225624")},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.Error(domain.ErrAccrualCircuitOpen)},
+				"2377225624":  {accrualfake.Processed(accrual)},
+			},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if !errors.Is(err, domain.ErrAccrualCircuitOpen) {
+					t.Fatalf("error = %v, want %v", err, domain.ErrAccrualCircuitOpen)
+				}
+				if calls := e.client.Calls("2377225624"); calls != 0 {
+					t.Errorf("second order was requested %d times, want 0", calls)
+				}
+				for _, number := range []string{"12345678903", "2377225624"} {
+					order := e.repo.order(t, number)
+					if order.LockedUntil != nil {
+						t.Errorf("order %s is still claimed", number)
+					}
+					if order.Attempts != 0 || order.Status != domain.OrderStatusNew {
+						t.Errorf("order %s = %s with %d attempts, want untouched", number, order.Status, order.Attempts)
+					}
+				}
+			},
+		},
+		{
+			name: "order past deadline is marked failed",
+			cfg:  Config{MaxAge: time.Hour},
+			orders: []domain.Order{{
+				Number:     "12345678903",
+				UserID:     1,
+				Status:     domain.OrderStatusProcessing,
+				UploadedAt: time.Now().Add(-2 * time.Hour),
+			}},
+			steps: map[string][]accrualfake.Step{
+				"12345678903": {accrualfake.Processed(accrual)},
+			},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				order := e.repo.order(t, "12345678903")
+				if order.Status != domain.OrderStatusInvalid {
+					t.Errorf("status = %s, want INVALID", order.Status)
+				}
+				if order.FailureReason == nil {
+					t.Error("failure reason is not recorded")
+				}
+				if calls := e.client.Calls("12345678903"); calls != 0 {
+					t.Errorf("accrual system was requested %d times, want 0", calls)
+				}
+				if len(e.metrics.finalized) != 1 || e.metrics.finalized[0] != domain.OrderStatusInvalid {
+					t.Errorf("finalized = %v, want [INVALID]", e.metrics.finalized)
+				}
+			},
+		},
+		{
+			name: "order past max attempts is marked failed",
+			cfg:  Config{MaxAttempts: 3},
+			orders: []domain.Order{{
+				Number:     "12345678903",
+				UserID:     1,
+				Status:     domain.OrderStatusNew,
+				Attempts:   3,
+				UploadedAt: time.Now(),
+			}},
+			passes: 1,
+			check: func(t *testing.T, e *testEnv, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if order := e.repo.order(t, "12345678903"); order.Status != domain.OrderStatusInvalid {
+					t.Errorf("status = %s, want INVALID", order.Status)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(tt.cfg, tt.orders...)
+			for number, steps := range tt.steps {
+				env.client.Script(number, steps...)
+			}
+
+			err := env.run(tt.passes)
+			tt.check(t, env, err)
+		})
+	}
+}
+
+func TestProcessOrdersStopping(t *testing.T) {
+	env := newTestEnv(Config{}, newOrder("12345678903"), newOrder("2377225624"))
+	env.client.Script("12345678903", accrualfake.Processed(domain.NewMoney(10, 0)))
+
+	claimCtx, stopClaims := context.WithCancel(context.Background())
+	stopClaims()
+
+	if err := env.worker.processOrders(claimCtx, context.Background(), env.worker.logger); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := env.client.Calls("12345678903"); calls != 0 {
+		t.Errorf("accrual system was requested %d times after stop, want 0", calls)
+	}
+	if len(env.repo.released) != 2 {
+		t.Errorf("released = %v, want both orders", env.repo.released)
+	}
+}
+
+func equalStatuses(a, b []domain.OrderStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// maxBackoffShift ограничивает показатель степени, чтобы сдвиг не переполнил time.Duration.
+const maxBackoffShift = 30
+
+// backoffDelay возвращает задержку перед следующей попыткой с экспоненциальным ростом и джиттером.
+// Задержка равна base*2^attempts, ограничена maxDelay и случайно уменьшается до половины,
+// чтобы заказы, загруженные одновременно, не опрашивались пачками в одни и те же моменты.
+func backoffDelay(attempts int, base, maxDelay time.Duration) time.Duration {
+	shift := min(max(attempts, 0), maxBackoffShift)
+
+	delay := base << shift
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+
+	half := delay / 2
+	//nolint:gosec // G404: криптостойкость для джиттера не требуется
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// OrdersCreatedChannel канал NOTIFY, в который триггер на таблице orders сообщает о новых заказах.
+	OrdersCreatedChannel = "orders_created"
+
+	// defaultReconnectBaseDelay начальная задержка переподключения слушателя.
+	defaultReconnectBaseDelay = 1 * time.Second
+	// defaultReconnectMaxDelay максимальная задержка переподключения слушателя.
+	defaultReconnectMaxDelay = 30 * time.Second
+)
+
+// OrderListener держит отдельное соединение с LISTEN на канале новых заказов
+// и будит воркеры, не дожидаясь очередного периодического опроса.
+// При обрыве соединения переподключается с экспоненциальной задержкой.
+type OrderListener struct {
+	dsn    string
+	wakeup chan struct{}
+	logger *slog.Logger
+}
+
+// NewOrderListener создает новый экземпляр OrderListener.
+func NewOrderListener(dsn string, logger *slog.Logger) *OrderListener {
+	return &OrderListener{
+		dsn: dsn,
+		// Одного ожидающего сигнала достаточно: разбуженный воркер захватит все готовые заказы пачкой
+		wakeup: make(chan struct{}, 1),
+		logger: logger.With(
+			"package", "worker",
+			"component", "OrderListener",
+		),
+	}
+}
+
+// Wakeup возвращает канал сигналов о новых заказах для Config.Wakeup.
+func (l *OrderListener) Wakeup() <-chan struct{} {
+	return l.wakeup
+}
+
+// Start слушает уведомления до отмены контекста.
+func (l *OrderListener) Start(ctx context.Context) {
+	attempt := 0
+	for ctx.Err() == nil {
+		connected, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if connected {
+			attempt = 0
+		}
+
+		delay := backoffDelay(attempt, defaultReconnectBaseDelay, defaultReconnectMaxDelay)
+		attempt++
+		l.logger.Warn("соединение LISTEN потеряно, переподключение", "error", err, "задержка", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	l.logger.Info("слушатель новых заказов остановлен")
+}
+
+// listen подключается, подписывается на канал и пересылает уведомления до ошибки соединения.
+// Возвращает true, если подписка была успешно оформлена.
+func (l *OrderListener) listen(ctx context.Context) (bool, error) {
+	conn, connectErr := pgx.Connect(ctx, l.dsn)
+	if connectErr != nil {
+		return false, fmt.Errorf("failed to connect: %w", connectErr)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), defaultReconnectBaseDelay)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+
+	if _, listenErr := conn.Exec(ctx, "LISTEN "+pgx.Identifier{OrdersCreatedChannel}.Sanitize()); listenErr != nil {
+		return false, fmt.Errorf("failed to listen: %w", listenErr)
+	}
+	l.logger.Info("подписка на новые заказы оформлена", "канал", OrdersCreatedChannel)
+
+	// Уведомления, отправленные до подписки, потеряны: будим воркер на всякий случай
+	l.notify()
+
+	for {
+		notification, waitErr := conn.WaitForNotification(ctx)
+		if waitErr != nil {
+			return true, fmt.Errorf("failed to wait for notification: %w", waitErr)
+		}
+		l.logger.Debug("получено уведомление о новом заказе", "id заказа", notification.Payload)
+		l.notify()
+	}
+}
+
+// notify посылает сигнал воркерам, не блокируясь, если сигнал уже ожидает обработки.
+func (l *OrderListener) notify() {
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,69 @@
+-- +goose Up
+CREATE TYPE ledger_direction AS ENUM ('DEBIT', 'CREDIT');
+
+CREATE SEQUENCE ledger_posting_seq;
+
+-- Журнал проводок по двойной записи: каждая операция (posting_id) состоит
+-- из дебета одного счета и кредита другого на одинаковую сумму.
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    posting_id BIGINT NOT NULL,
+    account VARCHAR(64) NOT NULL, -- user:<id> или системный счет system:*
+    user_id INTEGER REFERENCES users(id), -- заполнено только для счетов пользователей
+    direction ledger_direction NOT NULL,
+    amount_kop BIGINT NOT NULL CHECK (amount_kop > 0),
+    reference_type VARCHAR(32) NOT NULL,
+    reference_id INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (reference_type, reference_id, account)
+);
+
+CREATE INDEX idx_ledger_entries_user_id ON ledger_entries(user_id);
+CREATE INDEX idx_ledger_entries_posting_id ON ledger_entries(posting_id);
+
+-- Кэш текущего баланса, обновляется в той же транзакции, что и проводки.
+CREATE TABLE balances (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    current_kop BIGINT NOT NULL DEFAULT 0,
+    withdrawn_kop BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Перенос начислений по обработанным заказам
+CREATE TEMPORARY TABLE ledger_backfill ON COMMIT DROP AS
+SELECT nextval('ledger_posting_seq') AS posting_id, user_id, accrual AS amount_kop,
+       'order'::VARCHAR(32) AS reference_type, id AS reference_id, uploaded_at AS created_at
+FROM orders
+WHERE status = 'PROCESSED' AND accrual > 0;
+
+INSERT INTO ledger_backfill
+SELECT nextval('ledger_posting_seq'), user_id, amount_kop, 'withdrawal', id, processed_at
+FROM withdrawals
+WHERE amount_kop > 0;
+
+INSERT INTO ledger_entries (posting_id, account, user_id, direction, amount_kop, reference_type, reference_id, created_at)
+SELECT posting_id, 'user:' || user_id, user_id,
+       CASE reference_type WHEN 'order' THEN 'CREDIT' ELSE 'DEBIT' END::ledger_direction,
+       amount_kop, reference_type, reference_id, created_at
+FROM ledger_backfill
+UNION ALL
+SELECT posting_id,
+       CASE reference_type WHEN 'order' THEN 'system:accrual' ELSE 'system:withdrawal' END,
+       NULL,
+       CASE reference_type WHEN 'order' THEN 'DEBIT' ELSE 'CREDIT' END::ledger_direction,
+       amount_kop, reference_type, reference_id, created_at
+FROM ledger_backfill;
+
+INSERT INTO balances (user_id, current_kop, withdrawn_kop)
+SELECT u.id,
+       COALESCE(SUM(CASE l.direction WHEN 'CREDIT' THEN l.amount_kop ELSE -l.amount_kop END), 0),
+       COALESCE(SUM(l.amount_kop) FILTER (WHERE l.direction = 'DEBIT' AND l.reference_type = 'withdrawal'), 0)
+FROM users u
+LEFT JOIN ledger_entries l ON l.user_id = u.id
+GROUP BY u.id;
+
+-- +goose Down
+DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS ledger_entries;
+DROP SEQUENCE IF EXISTS ledger_posting_seq;
+DROP TYPE IF EXISTS ledger_direction;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE idempotency_keys (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL, -- sha256 от метода, пути и тела запроса
+    status_code INTEGER, -- NULL, пока первый запрос еще выполняется
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
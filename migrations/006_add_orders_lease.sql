@@ -0,0 +1,11 @@
+-- +goose Up
+-- Аренда заказа воркером: пока locked_until в будущем, другие воркеры и реплики заказ не берут.
+-- Истекшая аренда (например, после падения реплики) освобождает заказ автоматически.
+ALTER TABLE orders ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_pending_uploaded_at ON orders(uploaded_at)
+    WHERE status IN ('NEW', 'PROCESSING');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_pending_uploaded_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS locked_until;
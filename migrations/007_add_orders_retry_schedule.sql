@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE orders
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0, -- количество запросов в систему начислений
+    ADD COLUMN last_error TEXT, -- причина последней неудачной попытки
+    ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN failure_reason TEXT; -- причина перевода в INVALID без ответа системы начислений
+
+DROP INDEX IF EXISTS idx_orders_pending_uploaded_at;
+CREATE INDEX idx_orders_pending_next_attempt_at ON orders(next_attempt_at)
+    WHERE status IN ('NEW', 'PROCESSING');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_pending_next_attempt_at;
+CREATE INDEX idx_orders_pending_uploaded_at ON orders(uploaded_at)
+    WHERE status IN ('NEW', 'PROCESSING');
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS failure_reason;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Уведомление воркеров начислений о новом заказе; доставляется после фиксации транзакции.
+-- +goose StatementBegin
+CREATE FUNCTION notify_order_created() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('orders_created', NEW.id::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER orders_notify_created
+    AFTER INSERT ON orders
+    FOR EACH ROW EXECUTE FUNCTION notify_order_created();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS orders_notify_created ON orders;
+DROP FUNCTION IF EXISTS notify_order_created();
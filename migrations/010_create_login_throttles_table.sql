@@ -0,0 +1,13 @@
+-- +goose Up
+-- Счетчики неудачных входов по логину и по IP-адресу для прогрессивной блокировки.
+CREATE TABLE login_throttles (
+    scope VARCHAR(16) NOT NULL, -- login или ip
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS login_throttles;
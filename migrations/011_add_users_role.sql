@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    ADD COLUMN blocked_at TIMESTAMP WITH TIME ZONE, -- NULL, пока учетная запись не заблокирована
+    ADD COLUMN blocked_reason TEXT;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS role,
+    DROP COLUMN IF EXISTS blocked_at,
+    DROP COLUMN IF EXISTS blocked_reason;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Ручные корректировки баланса службой поддержки и возвраты списаний.
+-- Корректировка всегда проводится через журнал с reference_type = kind и reference_id = id.
+CREATE TABLE balance_adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    operator_id INTEGER NOT NULL REFERENCES users(id), -- администратор, выполнивший операцию
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('adjustment', 'withdrawal_reversal')),
+    direction ledger_direction NOT NULL, -- направление со стороны счета пользователя
+    amount_kop BIGINT NOT NULL CHECK (amount_kop > 0),
+    withdrawal_id INTEGER UNIQUE REFERENCES withdrawals(id), -- списание может быть возвращено только один раз
+    reason TEXT NOT NULL CHECK (reason <> ''),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((kind = 'withdrawal_reversal') = (withdrawal_id IS NOT NULL))
+);
+
+CREATE INDEX idx_balance_adjustments_user_id ON balance_adjustments(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS balance_adjustments;
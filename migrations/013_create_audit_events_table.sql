@@ -0,0 +1,88 @@
+-- +goose Up
+-- Журнал аудита событий безопасности и операций с баллами.
+-- Записи только добавляются; каждая запись содержит хеш предыдущей, поэтому изменение
+-- или удаление строки в обход триггеров обнаруживается проверкой цепочки.
+CREATE SEQUENCE audit_events_seq;
+
+CREATE TABLE audit_events (
+    id BIGINT PRIMARY KEY, -- назначается триггером под блокировкой, чтобы порядок id совпадал с порядком цепочки
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor_type VARCHAR(16) NOT NULL, -- user, system или anonymous
+    actor_id INTEGER, -- пользователь, выполнивший действие
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL DEFAULT '',
+    target_id VARCHAR(64) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    payload JSONB,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, id);
+CREATE INDEX idx_audit_events_action ON audit_events(action, id);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id, id);
+
+-- Хеш записи: sha256 от канонического JSON-массива всех полей, включая хеш предыдущей записи.
+-- +goose StatementBegin
+CREATE FUNCTION audit_event_hash(e audit_events) RETURNS CHAR(64) AS $$
+    SELECT encode(sha256(convert_to(jsonb_build_array(
+        e.prev_hash,
+        e.id,
+        to_char(e.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        e.actor_type,
+        e.actor_id,
+        e.action,
+        e.target_type,
+        e.target_id,
+        e.ip,
+        e.user_agent,
+        e.payload
+    )::TEXT, 'UTF8')), 'hex');
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_chain() RETURNS TRIGGER AS $$
+BEGIN
+    -- Вставки выстраиваются в очередь, чтобы каждая запись ссылалась на последнюю зафиксированную
+    PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+
+    NEW.id := nextval('audit_events_seq');
+    NEW.created_at := clock_timestamp();
+    NEW.prev_hash := COALESCE(
+        (SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1),
+        repeat('0', 64)
+    );
+    NEW.hash := audit_event_hash(NEW);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_chain
+    BEFORE INSERT ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_chain();
+
+CREATE TRIGGER audit_events_no_modify
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
+
+-- +goose Down
+DROP FUNCTION IF EXISTS audit_event_hash(audit_events);
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_immutable();
+DROP FUNCTION IF EXISTS audit_events_chain();
+DROP SEQUENCE IF EXISTS audit_events_seq;
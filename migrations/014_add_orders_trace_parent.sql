@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE orders
+    ADD COLUMN trace_parent TEXT; -- заголовок traceparent запроса, в котором заказ был загружен
+
+-- +goose Down
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS trace_parent;
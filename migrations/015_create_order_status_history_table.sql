@@ -0,0 +1,17 @@
+-- +goose Up
+-- История смены статусов заказа. Записывается в той же транзакции, что и сам переход.
+CREATE TABLE order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    from_status order_status NOT NULL,
+    to_status order_status NOT NULL,
+    accrual BIGINT, -- начисление в копейках для перехода в PROCESSED
+    reason TEXT, -- причина перехода в INVALID
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_status <> to_status)
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_status_history;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE order_status_history
+    ADD COLUMN attempt INTEGER; -- номер запроса в систему начислений, ответ на который сменил статус
+
+-- +goose Down
+ALTER TABLE order_status_history
+    DROP COLUMN IF EXISTS attempt;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Индексы для постраничного вывода списков пользователя по курсору (время, id).
+CREATE INDEX idx_orders_user_id_uploaded_at ON orders(user_id, uploaded_at, id);
+CREATE INDEX idx_withdrawals_user_id_processed_at ON withdrawals(user_id, processed_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_withdrawals_user_id_processed_at;
+DROP INDEX IF EXISTS idx_orders_user_id_uploaded_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Аренда ключа на время выполнения первого запроса. Если запрос не завершился (паника, падение процесса),
+-- после окончания аренды ключ снова может занять повтор запроса, не дожидаясь expires_at.
+ALTER TABLE idempotency_keys
+    ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+UPDATE idempotency_keys SET locked_until = created_at WHERE status_code IS NULL;
+
+-- +goose Down
+ALTER TABLE idempotency_keys
+    DROP COLUMN IF EXISTS locked_until;
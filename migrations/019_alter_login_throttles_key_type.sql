@@ -0,0 +1,10 @@
+-- +goose Up
+-- Логин при входе не ограничен по длине: с VARCHAR(255) длинный логин ломал учет неудачных попыток.
+ALTER TABLE login_throttles
+    ALTER COLUMN key TYPE TEXT;
+
+-- +goose Down
+DELETE FROM login_throttles WHERE length(key) > 255;
+
+ALTER TABLE login_throttles
+    ALTER COLUMN key TYPE VARCHAR(255);